@@ -2,21 +2,69 @@ package main
 
 import (
 	"game-server/internal/api"
+	"game-server/internal/events"
+	"game-server/internal/service"
+	"game-server/internal/snake"
+	"game-server/internal/store"
 	"log"
 	"net/http"
+	"os"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// Schema management: game-server migrate [status|up|down|to <version>]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Protocol schema: game-server schema, prints the snake message JSON Schema
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		if err := runSchema(); err != nil {
+			log.Fatalf("Schema generation failed: %v", err)
+		}
+		return
+	}
+
 	router := gin.Default()
 	PORT := ":8080"
 	router.Use(cors.Default())
 
+	// Open the match store shared by match making and game servers
+	gameStore, err := store.Open(store.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to open store: %v", err)
+	}
+	defer gameStore.Close()
+
+	if err := store.PrepareSchema(gameStore); err != nil {
+		log.Fatalf("Failed to prepare schema: %v", err)
+	}
+
+	snakeConfig, err := snake.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid snake configuration: %v", err)
+	}
+
+	// Game events flow from the match maker and game engines to their subscribers
+	bus := events.NewBus()
+
+	// Players are shared by the account routes and the game servers
+	playerService := service.NewPlayerService()
+
 	// Register API routes
-	api.PlayerRegisterRoutes(router)
-	api.SnakeGameDataRoutes(router)
-	api.MatchMakeRoutes(router)
+	api.PlayerRegisterRoutes(router, playerService)
+	api.SnakeGameDataRoutes(router, gameStore, bus, snakeConfig, playerService)
+	// The admin routes are disabled unless ADMIN_TOKEN is set
+	api.MatchMakeRoutes(router, gameStore, bus, os.Getenv("ADMIN_TOKEN"))
+	api.MatchHistoryRoutes(router, gameStore)
+	api.LeaderboardRoutes(router, gameStore, bus)
+	api.PlayerStatsRoutes(router, gameStore)
+	api.AchievementRoutes(router, gameStore, bus)
 
 	// Echo Server endpoint
 	router.GET("/api", func(c *gin.Context) {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"game-server/internal/store"
+	"log"
+	"strconv"
+)
+
+// runMigrate handles the migrate subcommand against the configured store
+func runMigrate(args []string) error {
+	gameStore, err := store.Open(store.ConfigFromEnv())
+	if err != nil {
+		return err
+	}
+	defer gameStore.Close()
+
+	migrator, ok := gameStore.(store.Migrator)
+	if !ok {
+		return fmt.Errorf("store does not support migrations")
+	}
+
+	current, err := migrator.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "status":
+		log.Printf("Schema version %d, latest %d", current, store.LatestSchemaVersion())
+		return nil
+	case "up":
+		return migrator.MigrateTo(store.LatestSchemaVersion())
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q, expected a positive number", args[1])
+			}
+		}
+		return migrator.MigrateTo(max(current-steps, 0))
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate to <version>")
+		}
+		target, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return migrator.MigrateTo(target)
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected status, up, down or to", command)
+	}
+}
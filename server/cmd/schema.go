@@ -0,0 +1,14 @@
+package main
+
+import (
+	"encoding/json"
+	"game-server/internal/snake"
+	"os"
+)
+
+// runSchema prints the JSON Schema of the snake WebSocket protocol
+func runSchema() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snake.ProtocolSchema())
+}
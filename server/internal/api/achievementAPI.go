@@ -0,0 +1,24 @@
+package api
+
+import (
+	"game-server/internal/events"
+	"game-server/internal/handler"
+	"game-server/internal/service"
+	"game-server/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AchievementRoutes(router *gin.Engine, achievementStore store.AchievementStore, bus *events.Bus) {
+	// create service
+	achievementService := service.NewAchievementService(achievementStore, bus)
+
+	// inject service into handler
+	achievementHandler := handler.NewAchievementHandler(achievementService)
+
+	// match maker and game engine events drive achievement progress
+	achievementService.Subscribe()
+
+	// every achievement with the player's progress and unlock time
+	router.GET("/api/players/:id/achievements", achievementHandler.GetPlayerAchievements)
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"game-server/internal/events"
+	"game-server/internal/handler"
+	"game-server/internal/service"
+	"game-server/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func LeaderboardRoutes(router *gin.Engine, leaderboardStore store.LeaderboardStore, bus *events.Bus) {
+	// create service
+	leaderboardService := service.NewLeaderboardService(leaderboardStore)
+
+	// inject service into handler
+	leaderboardHandler := handler.NewLeaderboardHandler(leaderboardService)
+
+	// finished snake matches feed the leaderboards
+	bus.Subscribe(events.MatchEnded, leaderboardService.RecordMatch)
+
+	// top players by metric (score, wins, rating) and window (daily, weekly, all)
+	router.GET("/api/leaderboards/:gameId", leaderboardHandler.GetLeaderboard)
+}
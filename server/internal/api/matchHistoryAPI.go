@@ -0,0 +1,20 @@
+package api
+
+import (
+	"game-server/internal/handler"
+	"game-server/internal/service"
+	"game-server/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func MatchHistoryRoutes(router *gin.Engine, matchStore store.MatchStore) {
+	// create service
+	matchHistoryService := service.NewMatchHistoryService(matchStore)
+
+	// inject service into handler
+	matchHistoryHandler := handler.NewMatchHistoryHandler(matchHistoryService)
+
+	// past matches of a player, filtered by gameId, from, to and result
+	router.GET("/api/players/:id/matches", matchHistoryHandler.GetHistory)
+}
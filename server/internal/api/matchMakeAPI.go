@@ -1,18 +1,40 @@
 package api
 
 import (
+	"game-server/internal/events"
 	"game-server/internal/handler"
+	"game-server/internal/service"
+	"game-server/internal/store"
 	"github.com/gin-gonic/gin"
 )
 // Match Make Routes for player Match Make 
-func MatchMakeRoutes(router * gin.Engine){
+func MatchMakeRoutes(router * gin.Engine, gameStore store.Store, bus *events.Bus, adminToken string){
+	// create services sharing the same store
+	penaltyService := service.NewPenaltyService(gameStore)
+	matchMakeService := service.NewMatchMakeService(gameStore, penaltyService, bus)
+
 	// create handler instances
-	matchMakeHandler := handler.NewMatchMakeHandler()
+	matchMakeHandler := handler.NewMatchMakeHandler(matchMakeService)
+	penaltyHandler := handler.NewPenaltyHandler(penaltyService)
+
+	// players leaving a running snake match count as abandons
+	bus.Subscribe(events.PlayerAbandoned, matchMakeService.AbandonMatch)
+	// finished matches free their players to queue again
+	bus.Subscribe(events.MatchEnded, matchMakeService.FinishMatch)
 
 	// Add to the queue
 	router.POST("/api/match-make/:playerId/:gameId", matchMakeHandler.AddQueue)
 	// Remove from the queue
 	router.PATCH("/api/match-make/:playerId", matchMakeHandler.RemoveQueue)
-	// Get Match if it already made
+	// Get Match if it already made, or the match on offer
 	router.GET("/api/match-make/:playerId", matchMakeHandler.GetMatch)
-};
\ No newline at end of file
+	// Answer the ready check of an offered match
+	router.POST("/api/match-offer/:playerId/accept", matchMakeHandler.AcceptMatch)
+	router.POST("/api/match-offer/:playerId/decline", matchMakeHandler.DeclineMatch)
+
+	// Admin: inspect and clear queue penalties
+	admin := router.Group("/api/admin", handler.RequireAdmin(adminToken))
+	admin.GET("/penalties", penaltyHandler.ListPenalties)
+	admin.GET("/penalties/:playerId", penaltyHandler.GetPenalty)
+	admin.DELETE("/penalties/:playerId", penaltyHandler.ClearPenalty)
+};
@@ -7,10 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func PlayerRegisterRoutes(router *gin.Engine) {
-	// create service
-	playerService := service.NewPlayerService()
-
+func PlayerRegisterRoutes(router *gin.Engine, playerService *service.PlayerService) {
 	// inject service into handler
 	playerHandler := handler.NewPlayerHandler(playerService)
 
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"game-server/internal/handler"
+	"game-server/internal/service"
+	"game-server/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PlayerStatsRoutes(router *gin.Engine, statsStore store.StatsStore) {
+	// create service
+	playerStatsService := service.NewPlayerStatsService(statsStore)
+
+	// inject service into handler
+	playerStatsHandler := handler.NewPlayerStatsHandler(playerStatsService)
+
+	// aggregated snake statistics across all snake game modes
+	router.GET("/api/players/:id/stats/snake", playerStatsHandler.GetSnakeStats)
+}
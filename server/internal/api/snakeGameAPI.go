@@ -1,16 +1,19 @@
 package api
 
 import (
+	"game-server/internal/events"
 	"game-server/internal/handler"
+	"game-server/internal/service"
 	"game-server/internal/snake"
+	"game-server/internal/store"
 	"github.com/gin-gonic/gin"
 )
 
 
 
-func SnakeGameDataRoutes(router *gin.Engine) {
-	// create snake service to communicate each other
-	snakeService := snake.NewSnakeService()
+func SnakeGameDataRoutes(router *gin.Engine, gameStore store.Store, bus *events.Bus, snakeConfig snake.Config, playerService *service.PlayerService) {
+	// create snake service to communicate each other, snakes are named after their players
+	snakeService := snake.NewSnakeService(gameStore, gameStore, bus, snakeConfig, playerService.Username)
 	
 	// create snake handler to handle snake game meta data
 	snakeGameHandler := handler.NewSnakeHandler(snakeService)
@@ -19,6 +22,17 @@ func SnakeGameDataRoutes(router *gin.Engine) {
 	router.GET("/api/game/snake/meta-data",snakeGameHandler.MetaData)
 	// get player match specific metadata
 	router.GET("/api/game/snake/meta-data/:playerId", snakeGameHandler.GameMetaData)
+	// JSON Schema of the messages sent over the game socket
+	router.GET("/api/game/snake/protocol", snakeGameHandler.ProtocolSchema)
+	// running matches that can be watched
+	router.GET("/api/matches/live", snakeGameHandler.LiveMatches)
 	// main game logic end point 
-	router.GET("/ws", snake.WsHandler)
+	router.GET("/ws", snakeService.WsHandler)
+	// watch a running match without playing in it
+	router.GET("/ws/spectate", snakeService.SpectateHandler)
+	// play a finished match back
+	router.GET("/ws/replay", snakeService.ReplayHandler)
+
+	// tell players in a match about achievements they unlock while playing
+	bus.Subscribe(events.AchievementUnlocked, snakeService.NotifyAchievement)
 }
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"game-server/internal/store"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Type string
+
+const (
+	// Match maker
+	MatchCreated Type = "match_created"
+
+	// Snake engine
+	ScoreChanged    Type = "score_changed"    // Value is the new score
+	PlayerAlive     Type = "player_alive"     // Value is seconds alive, sent about once a second
+	PlayerDied      Type = "player_died"      // Cause and Killer are set
+	PlayerFinished  Type = "player_finished"  // Value is the final placement
+	PlayerAbandoned Type = "player_abandoned" // left a running multiplayer match
+	MatchEnded      Type = "match_ended"      // Results holds the final standings
+
+	// Achievements
+	AchievementUnlocked Type = "achievement_unlocked" // Achievement holds the definition id
+)
+
+// subscriberBuffer is how many events a slow subscriber can fall behind
+// before its events are dropped
+const subscriberBuffer = 256
+
+type Event struct {
+	Type        Type
+	Time        time.Time
+	Tick        int64 // simulation tick, set by the snake engine
+	MatchId     string
+	GameId      string
+	PlayerId    string
+	Players     []string
+	Value       int
+	Cause       string
+	Killer      string
+	Achievement string
+	Results     []store.PlayerResult
+}
+
+type Handler func(e Event)
+
+// Bus delivers published events to subscribers. Each subscriber runs on its
+// own goroutine and sees events in publish order. Publishing never blocks, a
+// subscriber too far behind misses events.
+type Bus struct {
+	subscribers map[Type][]chan Event
+	mu          sync.RWMutex
+	dropped     atomic.Int64
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[Type][]chan Event),
+	}
+}
+
+func (b *Bus) Subscribe(eventType Type, handler Handler) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[eventType] = append(b.subscribers[eventType], ch)
+	b.mu.Unlock()
+
+	go func() {
+		for e := range ch {
+			handler(e)
+		}
+	}()
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers[e.Type]
+	b.mu.RUnlock()
+
+	if len(subscribers) == 0 {
+		return
+	}
+	for _, ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+			log.Printf("Event subscriber for %v is too far behind, dropping the event", e.Type)
+		}
+	}
+}
+
+// Dropped is how many events were dropped for slow subscribers so far
+func (b *Bus) Dropped() int64 {
+	return b.dropped.Load()
+}
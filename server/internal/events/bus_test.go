@@ -0,0 +1,68 @@
+package events
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBusDeliversInPublishOrder(t *testing.T) {
+	bus := NewBus()
+	got := make(chan Event, 3)
+	bus.Subscribe(ScoreChanged, func(e Event) { got <- e })
+	bus.Subscribe(PlayerDied, func(e Event) { t.Errorf("PlayerDied subscriber got %v", e.Type) })
+
+	for value := 1; value <= 3; value++ {
+		bus.Publish(Event{Type: ScoreChanged, Value: value})
+	}
+	for want := 1; want <= 3; want++ {
+		select {
+		case e := <-got:
+			if e.Value != want || e.Time.IsZero() {
+				t.Fatalf("event %v = %+v, want value %v with a time", want, e, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %v was not delivered", want)
+		}
+	}
+}
+
+func TestBusDropsEventsForSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	var delivered atomic.Int64
+	bus.Subscribe(ScoreChanged, func(e Event) {
+		<-release
+		delivered.Add(1)
+	})
+
+	// the handler holds one event and the buffer the next ones, the rest
+	// must be dropped without blocking the publisher
+	const published = subscriberBuffer + 10
+	done := make(chan struct{})
+	go func() {
+		for range published {
+			bus.Publish(Event{Type: ScoreChanged})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+	if dropped := bus.Dropped(); dropped < 9 || dropped > 10 {
+		t.Fatalf("Dropped = %d, want the events past the buffer", dropped)
+	}
+
+	close(release)
+	want := published - bus.Dropped()
+	deadline := time.After(time.Second)
+	for delivered.Load() < want {
+		select {
+		case <-deadline:
+			t.Fatalf("delivered %d events, want %d", delivered.Load(), want)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
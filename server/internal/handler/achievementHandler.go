@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"game-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AchievementHandler struct {
+	achievementService *service.AchievementService
+}
+
+func NewAchievementHandler(as *service.AchievementService) *AchievementHandler {
+	return &AchievementHandler{
+		achievementService: as,
+	}
+}
+
+// Player achievements handler
+func (ah *AchievementHandler) GetPlayerAchievements(c *gin.Context) {
+	achievements, err := ah.achievementService.GetPlayerAchievements(c.Param("id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, achievements)
+}
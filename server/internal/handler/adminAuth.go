@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin lets through requests carrying the admin token as a bearer
+// token. Without a token configured every request is refused.
+func RequireAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(403, gin.H{"error": "admin API is disabled"})
+			return
+		}
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(401, gin.H{"error": "admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
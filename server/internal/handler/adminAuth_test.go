@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{name: "right token", token: "secret", authorization: "Bearer secret", want: http.StatusOK},
+		{name: "wrong token", token: "secret", authorization: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "no token", token: "secret", want: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "secret", authorization: "secret", want: http.StatusUnauthorized},
+		// without a configured token nobody gets in
+		{name: "disabled", authorization: "Bearer ", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/admin", RequireAdmin(tt.token), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
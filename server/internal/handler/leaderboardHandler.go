@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"game-server/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LeaderboardHandler struct {
+	leaderboardService *service.LeaderboardService
+}
+
+func NewLeaderboardHandler(ls *service.LeaderboardService) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		leaderboardService: ls,
+	}
+}
+
+// Leaderboard handler
+func (lh *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	leaderboard, err := lh.leaderboardService.GetLeaderboard(service.LeaderboardRequest{
+		GameId:   c.Param("gameId"),
+		Metric:   c.Query("metric"),
+		Window:   c.Query("window"),
+		Limit:    limit,
+		PlayerId: c.Query("playerId"),
+	})
+	if errors.Is(err, service.ErrInvalidLeaderboardQuery) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, leaderboard)
+}
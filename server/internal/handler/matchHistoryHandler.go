@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"game-server/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MatchHistoryHandler struct {
+	matchHistoryService *service.MatchHistoryService
+}
+
+func NewMatchHistoryHandler(mhs *service.MatchHistoryService) *MatchHistoryHandler {
+	return &MatchHistoryHandler{
+		matchHistoryService: mhs,
+	}
+}
+
+// Player match history handler
+func (mhh *MatchHistoryHandler) GetHistory(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	history, err := mhh.matchHistoryService.GetHistory(service.MatchHistoryRequest{
+		PlayerId: c.Param("id"),
+		GameId:   c.Query("gameId"),
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		Result:   c.Query("result"),
+		Cursor:   c.Query("cursor"),
+		Limit:    limit,
+	})
+	if errors.Is(err, service.ErrInvalidHistoryQuery) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, history)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"game-server/internal/service"
 	"log"
@@ -12,10 +13,10 @@ type MatchMakeHandler struct{
 }
 
 // Create new MatchMakeHandler
-func NewMatchMakeHandler() *MatchMakeHandler{
+func NewMatchMakeHandler(ms *service.MatchMakeService) *MatchMakeHandler{
 	log.Println("Match-make service initiate")
 	return &MatchMakeHandler{
-		matchMakeService: service.NewMatchMakeService(),
+		matchMakeService: ms,
 	}
 }
 // Add player to the qeueue
@@ -25,6 +26,13 @@ func(mh *MatchMakeHandler)AddQueue(c *gin.Context){
 	log.Printf("Player %v request for match-make for game %v", playerId, gameId)
 	
 	err := mh.matchMakeService.AddQueue(playerId, gameId)
+	if errors.Is(err, service.ErrQueueCooldown){
+		c.JSON(429, gin.H{
+			"message": "queue cooldown active",
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil{
 		c.JSON(500, gin.H{
 			"massage": "failed to add queue",
@@ -59,6 +67,40 @@ func(mh *MatchMakeHandler)RemoveQueue(c *gin.Context){
 	})
 }
 
+// Accept the match offered to the player
+func (mh *MatchMakeHandler) AcceptMatch(c *gin.Context) {
+	playerId := c.Param("playerId")
+
+	err := mh.matchMakeService.AcceptMatch(playerId)
+	if errors.Is(err, service.ErrNoMatchOffer) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "match accepted", "playerId": playerId})
+}
+
+// Decline the match offered to the player, which counts as a dodge
+func (mh *MatchMakeHandler) DeclineMatch(c *gin.Context) {
+	playerId := c.Param("playerId")
+
+	err := mh.matchMakeService.DeclineMatch(playerId)
+	if errors.Is(err, service.ErrNoMatchOffer) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "match declined", "playerId": playerId})
+}
+
 // Get Match Stats from the queue
 func (mh *MatchMakeHandler) GetMatch(c *gin.Context) {
 	playerId := c.Param("playerId")
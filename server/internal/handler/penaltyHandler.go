@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"game-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PenaltyHandler struct {
+	penaltyService *service.PenaltyService
+}
+
+func NewPenaltyHandler(ps *service.PenaltyService) *PenaltyHandler {
+	return &PenaltyHandler{
+		penaltyService: ps,
+	}
+}
+
+// List players with active penalties
+func (ph *PenaltyHandler) ListPenalties(c *gin.Context) {
+	penalties, err := ph.penaltyService.ListPenalties()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"penalties": penalties})
+}
+
+// Get a single player's penalty record
+func (ph *PenaltyHandler) GetPenalty(c *gin.Context) {
+	playerId := c.Param("playerId")
+
+	penalty, err := ph.penaltyService.GetPenalty(playerId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, penalty)
+}
+
+// Clear a player's penalties and cooldown
+func (ph *PenaltyHandler) ClearPenalty(c *gin.Context) {
+	playerId := c.Param("playerId")
+
+	if err := ph.penaltyService.ClearPenalty(playerId); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "penalties cleared", "playerId": playerId})
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"game-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PlayerStatsHandler struct {
+	playerStatsService *service.PlayerStatsService
+}
+
+func NewPlayerStatsHandler(pss *service.PlayerStatsService) *PlayerStatsHandler {
+	return &PlayerStatsHandler{
+		playerStatsService: pss,
+	}
+}
+
+// Snake statistics handler
+func (psh *PlayerStatsHandler) GetSnakeStats(c *gin.Context) {
+	stats, err := psh.playerStatsService.GetSnakeStats(c.Param("id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, stats)
+}
@@ -31,4 +31,14 @@ func (sh *SnakeHandler) GameMetaData(c *gin.Context){
 		"message": "player specific game meta data",
 		"playerId": playerId,
 	})
-}
\ No newline at end of file
+}
+
+// ProtocolSchema serves the JSON Schema of the game socket messages
+func (sh *SnakeHandler) ProtocolSchema(c *gin.Context) {
+	c.JSON(200, snake.ProtocolSchema())
+}
+
+// LiveMatches lists the running matches for the watch live page
+func (sh *SnakeHandler) LiveMatches(c *gin.Context) {
+	c.JSON(200, gin.H{"matches": sh.snakeService.LiveMatches()})
+}
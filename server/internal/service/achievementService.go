@@ -0,0 +1,182 @@
+package service
+
+import (
+	"game-server/internal/events"
+	"game-server/internal/store"
+	"log"
+	"time"
+)
+
+// Achievement is a goal players unlock by reaching Target qualifying events
+type Achievement struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Target      int    `json:"target"`
+	// event counted towards the achievement, limited to GameId when set
+	event     events.Type
+	gameId    string
+	qualifies func(e events.Event) bool
+}
+
+var Achievements = []Achievement{
+	{
+		Id:          "first-match",
+		Name:        "Matched Up",
+		Description: "Get placed into your first match",
+		Target:      1,
+		event:       events.MatchCreated,
+	},
+	{
+		Id:          "snake-score-50",
+		Name:        "Big Appetite",
+		Description: "Score 50 in one snake game",
+		Target:      1,
+		event:       events.ScoreChanged,
+		qualifies:   func(e events.Event) bool { return e.Value >= 50 },
+	},
+	{
+		Id:          "four-snake-wins-10",
+		Name:        "Last Snake Standing",
+		Description: "Win 10 four-snake games",
+		Target:      10,
+		event:       events.PlayerFinished,
+		gameId:      "four-snake-game",
+		qualifies:   func(e events.Event) bool { return e.Value == 1 },
+	},
+	{
+		Id:          "snake-survive-5m",
+		Name:        "Survivor",
+		Description: "Survive 5 minutes in one snake game",
+		Target:      1,
+		event:       events.PlayerAlive,
+		qualifies:   func(e events.Event) bool { return e.Value >= 300 },
+	},
+}
+
+type AchievementService struct {
+	store store.AchievementStore
+	bus   *events.Bus
+}
+
+// Responses
+type PlayerAchievementResponse struct {
+	Achievement
+	Progress   int        `json:"progress"`
+	Unlocked   bool       `json:"unlocked"`
+	UnlockedAt *time.Time `json:"unlockedAt"`
+}
+
+type PlayerAchievementsResponse struct {
+	PlayerId     string                      `json:"playerId"`
+	Achievements []PlayerAchievementResponse `json:"achievements"`
+}
+
+func NewAchievementService(achievementStore store.AchievementStore, bus *events.Bus) *AchievementService {
+	return &AchievementService{
+		store: achievementStore,
+		bus:   bus,
+	}
+}
+
+// Subscribe evaluates achievements against every event type they count
+func (as *AchievementService) Subscribe() {
+	subscribed := make(map[events.Type]bool)
+	for _, a := range Achievements {
+		if !subscribed[a.event] {
+			as.bus.Subscribe(a.event, as.HandleEvent)
+			subscribed[a.event] = true
+		}
+	}
+}
+
+// HandleEvent advances the progress of every achievement the event qualifies for
+func (as *AchievementService) HandleEvent(e events.Event) {
+	// Match maker events concern every player of the match
+	playerIds := e.Players
+	if e.PlayerId != "" {
+		playerIds = []string{e.PlayerId}
+	}
+
+	for _, a := range Achievements {
+		if a.event != e.Type || (a.gameId != "" && a.gameId != e.GameId) {
+			continue
+		}
+		if a.qualifies != nil && !a.qualifies(e) {
+			continue
+		}
+		for _, playerId := range playerIds {
+			if err := as.advance(a, playerId, e); err != nil {
+				log.Printf("Failed to update achievement %v for %v: %v", a.Id, playerId, err)
+			}
+		}
+	}
+}
+
+func (as *AchievementService) advance(a Achievement, playerId string, e events.Event) error {
+	current, err := as.store.ListAchievements(playerId)
+	if err != nil {
+		return err
+	}
+
+	progress := store.PlayerAchievement{PlayerId: playerId, AchievementId: a.Id}
+	for _, pa := range current {
+		if pa.AchievementId == a.Id {
+			progress = pa
+		}
+	}
+	if !progress.UnlockedAt.IsZero() {
+		return nil
+	}
+
+	progress.Progress++
+	if progress.Progress >= a.Target {
+		progress.UnlockedAt = e.Time
+	}
+	if err := as.store.SaveAchievement(progress); err != nil {
+		return err
+	}
+
+	if !progress.UnlockedAt.IsZero() {
+		log.Printf("Player %v unlocked achievement %v", playerId, a.Id)
+		as.bus.Publish(events.Event{
+			Type:        events.AchievementUnlocked,
+			MatchId:     e.MatchId,
+			GameId:      e.GameId,
+			PlayerId:    playerId,
+			Achievement: a.Id,
+		})
+	}
+	return nil
+}
+
+// GetPlayerAchievements lists every achievement with the player's progress
+func (as *AchievementService) GetPlayerAchievements(playerId string) (*PlayerAchievementsResponse, error) {
+	current, err := as.store.ListAchievements(playerId)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]store.PlayerAchievement, len(current))
+	for _, pa := range current {
+		byId[pa.AchievementId] = pa
+	}
+
+	resp := &PlayerAchievementsResponse{
+		PlayerId:     playerId,
+		Achievements: make([]PlayerAchievementResponse, 0, len(Achievements)),
+	}
+	for _, a := range Achievements {
+		pa := byId[a.Id]
+		entry := PlayerAchievementResponse{
+			Achievement: a,
+			Progress:    pa.Progress,
+		}
+		if !pa.UnlockedAt.IsZero() {
+			unlockedAt := pa.UnlockedAt
+			entry.Unlocked = true
+			entry.UnlockedAt = &unlockedAt
+		}
+		resp.Achievements = append(resp.Achievements, entry)
+	}
+	return resp, nil
+}
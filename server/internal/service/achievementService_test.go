@@ -0,0 +1,73 @@
+package service
+
+import (
+	"game-server/internal/events"
+	"game-server/internal/store"
+	"testing"
+	"time"
+)
+
+func progressOf(t *testing.T, as *AchievementService, playerId, achievementId string) PlayerAchievementResponse {
+	t.Helper()
+	resp, err := as.GetPlayerAchievements(playerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range resp.Achievements {
+		if a.Id == achievementId {
+			return a
+		}
+	}
+	t.Fatalf("achievement %v is not listed", achievementId)
+	return PlayerAchievementResponse{}
+}
+
+func TestAchievementCountsOnlyQualifyingEvents(t *testing.T) {
+	bus := events.NewBus()
+	as := NewAchievementService(store.NewMemoryStore(), bus)
+	unlocked := make(chan events.Event, 1)
+	bus.Subscribe(events.AchievementUnlocked, func(e events.Event) { unlocked <- e })
+
+	finish := func(gameId string, placement int) {
+		as.HandleEvent(events.Event{Type: events.PlayerFinished, GameId: gameId, PlayerId: "a", Value: placement, Time: time.Now()})
+	}
+	finish("four-snake-game", 2)
+	finish("snake-game", 1)
+	for range 9 {
+		finish("four-snake-game", 1)
+	}
+	if a := progressOf(t, as, "a", "four-snake-wins-10"); a.Progress != 9 || a.Unlocked {
+		t.Fatalf("after 9 wins: %+v", a)
+	}
+
+	finish("four-snake-game", 1)
+	a := progressOf(t, as, "a", "four-snake-wins-10")
+	if a.Progress != 10 || !a.Unlocked || a.UnlockedAt == nil {
+		t.Fatalf("after 10 wins: %+v", a)
+	}
+	select {
+	case e := <-unlocked:
+		if e.PlayerId != "a" || e.Achievement != "four-snake-wins-10" {
+			t.Fatalf("unlock event = %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no unlock event was published")
+	}
+
+	// an unlocked achievement stops counting
+	finish("four-snake-game", 1)
+	if a := progressOf(t, as, "a", "four-snake-wins-10"); a.Progress != 10 {
+		t.Fatalf("after unlocking: %+v", a)
+	}
+}
+
+func TestAchievementMatchEventsCountForEveryPlayer(t *testing.T) {
+	as := NewAchievementService(store.NewMemoryStore(), events.NewBus())
+	as.HandleEvent(events.Event{Type: events.MatchCreated, GameId: "snake-game", Players: []string{"a", "b"}, Time: time.Now()})
+
+	for _, playerId := range []string{"a", "b"} {
+		if a := progressOf(t, as, playerId, "first-match"); !a.Unlocked {
+			t.Errorf("first-match for %v = %+v", playerId, a)
+		}
+	}
+}
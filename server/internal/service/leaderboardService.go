@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"game-server/internal/events"
+	"game-server/internal/store"
+	"log"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	WindowDaily   = "daily"
+	WindowWeekly  = "weekly"
+	WindowAllTime = "all"
+
+	DefaultLeaderboardLimit = 10
+	MaxLeaderboardLimit     = 100
+
+	InitialRating = 1000
+	RatingKFactor = 32
+)
+
+var ErrInvalidLeaderboardQuery = errors.New("invalid leaderboard query")
+
+type LeaderboardService struct {
+	store store.LeaderboardStore
+}
+
+// Requests
+type LeaderboardRequest struct {
+	GameId   string
+	Metric   string
+	Window   string
+	Limit    int
+	PlayerId string
+}
+
+// Responses
+type LeaderboardResponse struct {
+	GameId  string                   `json:"gameId"`
+	Metric  string                   `json:"metric"`
+	Window  string                   `json:"window"`
+	Entries []store.LeaderboardEntry `json:"entries"`
+	// The requesting player's own entry, nil if they have no results in the window
+	Player *store.LeaderboardEntry `json:"player"`
+}
+
+func NewLeaderboardService(leaderboardStore store.LeaderboardStore) *LeaderboardService {
+	return &LeaderboardService{
+		store: leaderboardStore,
+	}
+}
+
+// RecordMatch persists final results and updates the players' ratings
+func (ls *LeaderboardService) RecordMatch(e events.Event) {
+	matchId, gameId, results := e.MatchId, e.GameId, e.Results
+	if err := ls.store.SaveMatchResults(matchId, results); err != nil {
+		log.Printf("Failed to save results of match %v: %v", matchId, err)
+		return
+	}
+
+	// Ratings only move when players compete against each other, players
+	// that never joined have no placement to rate
+	placed := slices.DeleteFunc(slices.Clone(results), func(r store.PlayerResult) bool { return r.Placement == nil })
+	if len(placed) < 2 {
+		return
+	}
+	if err := ls.updateRatings(gameId, placed); err != nil {
+		log.Printf("Failed to update ratings for match %v: %v", matchId, err)
+	}
+}
+
+// GetLeaderboard returns the top players of a game for a metric and time window
+func (ls *LeaderboardService) GetLeaderboard(req LeaderboardRequest) (*LeaderboardResponse, error) {
+	if req.Metric == "" {
+		req.Metric = store.MetricScore
+	}
+	if req.Window == "" {
+		req.Window = WindowAllTime
+	}
+	if req.Limit <= 0 {
+		req.Limit = DefaultLeaderboardLimit
+	}
+	req.Limit = min(req.Limit, MaxLeaderboardLimit)
+
+	switch req.Metric {
+	case store.MetricScore, store.MetricWins, store.MetricRating:
+	default:
+		return nil, fmt.Errorf("%w: unknown metric %q", ErrInvalidLeaderboardQuery, req.Metric)
+	}
+	since, err := windowStart(req.Window, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ls.store.Leaderboard(store.LeaderboardQuery{
+		GameId:   req.GameId,
+		Metric:   req.Metric,
+		Since:    since,
+		Limit:    req.Limit,
+		PlayerId: req.PlayerId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &LeaderboardResponse{
+		GameId:  req.GameId,
+		Metric:  req.Metric,
+		Window:  req.Window,
+		Entries: entries,
+	}
+	// A player outside the top N is returned after it
+	if len(entries) > req.Limit {
+		resp.Entries = entries[:req.Limit]
+	}
+	for i := range entries {
+		if req.PlayerId != "" && entries[i].PlayerId == req.PlayerId {
+			resp.Player = &entries[i]
+		}
+	}
+	return resp, nil
+}
+
+// updateRatings applies pairwise Elo: each player is compared with every
+// other player and the K factor is split across opponents
+func (ls *LeaderboardService) updateRatings(gameId string, results []store.PlayerResult) error {
+	playerIds := make([]string, 0, len(results))
+	for _, r := range results {
+		playerIds = append(playerIds, r.PlayerId)
+	}
+
+	current, err := ls.store.GetRatings(gameId, playerIds)
+	if err != nil {
+		return err
+	}
+	ratingOf := func(playerId string) int {
+		if r, ok := current[playerId]; ok {
+			return r.Rating
+		}
+		return InitialRating
+	}
+
+	updated := make([]store.Rating, 0, len(results))
+	k := float64(RatingKFactor) / float64(len(results)-1)
+	for _, r := range results {
+		rating := ratingOf(r.PlayerId)
+		delta := 0.0
+		for _, o := range results {
+			if o.PlayerId == r.PlayerId {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, float64(ratingOf(o.PlayerId)-rating)/400))
+			actual := 0.5
+			if *r.Placement < *o.Placement {
+				actual = 1
+			} else if *r.Placement > *o.Placement {
+				actual = 0
+			}
+			delta += k * (actual - expected)
+		}
+
+		updated = append(updated, store.Rating{
+			PlayerId: r.PlayerId,
+			GameId:   gameId,
+			Rating:   rating + int(math.Round(delta)),
+			Games:    current[r.PlayerId].Games + 1,
+		})
+	}
+	return ls.store.SaveRatings(updated)
+}
+
+// windowStart returns the UTC start of the current day or ISO week
+func windowStart(window string, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch window {
+	case WindowDaily:
+		return today, nil
+	case WindowWeekly:
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -daysSinceMonday), nil
+	case WindowAllTime:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, fmt.Errorf("%w: unknown window %q", ErrInvalidLeaderboardQuery, window)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"game-server/internal/events"
+	"game-server/internal/store"
+	"testing"
+	"time"
+)
+
+func placement(n int) *int {
+	return &n
+}
+
+func TestRecordMatchUpdatesRatings(t *testing.T) {
+	s := store.NewMemoryStore()
+	if err := s.SaveMatch(store.Match{GameId: "snake", MatchId: "m1", Players: []string{"a", "b", "c", "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLeaderboardService(s)
+
+	ls.RecordMatch(events.Event{
+		Type:    events.MatchEnded,
+		MatchId: "m1",
+		GameId:  "snake",
+		Results: []store.PlayerResult{
+			{PlayerId: "a", FinalScore: 5, Placement: placement(1)},
+			{PlayerId: "b", FinalScore: 2, Placement: placement(2)},
+			{PlayerId: "c", FinalScore: 2, Placement: placement(2)},
+			// d never joined and is left out of the ratings
+			{PlayerId: "d", DeathReason: "Never joined"},
+		},
+	})
+
+	ratings, err := s.GetRatings("snake", []string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// even players split K across two opponents, a tie moves nothing
+	want := map[string]int{"a": 1016, "b": 992, "c": 992}
+	if len(ratings) != len(want) {
+		t.Errorf("GetRatings = %+v, want only the players that joined", ratings)
+	}
+	for playerId, rating := range want {
+		if r := ratings[playerId]; r.Rating != rating || r.Games != 1 {
+			t.Errorf("rating of %v = %+v, want %v after 1 game", playerId, r, rating)
+		}
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	// a Wednesday afternoon
+	now := time.Date(2026, 10, 21, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		window string
+		want   time.Time
+	}{
+		{WindowDaily, time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)},
+		{WindowWeekly, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{WindowAllTime, time.Time{}},
+	}
+	for _, tt := range tests {
+		got, err := windowStart(tt.window, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("windowStart(%q) = %v, %v, want %v", tt.window, got, err, tt.want)
+		}
+	}
+	if _, err := windowStart("monthly", now); !errors.Is(err, ErrInvalidLeaderboardQuery) {
+		t.Errorf("windowStart(monthly) error = %v, want ErrInvalidLeaderboardQuery", err)
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"game-server/internal/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHistoryLimit = 20
+	MaxHistoryLimit     = 100
+)
+
+var ErrInvalidHistoryQuery = errors.New("invalid match history query")
+
+type MatchHistoryService struct {
+	store store.MatchStore
+}
+
+// Requests
+type MatchHistoryRequest struct {
+	PlayerId string
+	GameId   string
+	// RFC3339 timestamps or YYYY-MM-DD dates
+	From   string
+	To     string
+	Result string
+	Cursor string
+	Limit  int
+}
+
+// Responses
+type MatchHistoryEntry struct {
+	MatchId         string              `json:"matchId"`
+	GameId          string              `json:"gameId"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	StartedAt       *time.Time          `json:"startedAt"`
+	EndedAt         *time.Time          `json:"endedAt"`
+	DurationSeconds *int64              `json:"durationSeconds"`
+	Result          string              `json:"result"`
+	FinalScore      *int                `json:"finalScore"`
+	Placement       *int                `json:"placement"`
+	DeathReason     string              `json:"deathReason,omitempty"`
+	Opponents       []store.MatchPlayer `json:"opponents"`
+}
+
+type MatchHistoryResponse struct {
+	PlayerId   string              `json:"playerId"`
+	Matches    []MatchHistoryEntry `json:"matches"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+}
+
+func NewMatchHistoryService(matchStore store.MatchStore) *MatchHistoryService {
+	return &MatchHistoryService{
+		store: matchStore,
+	}
+}
+
+// GetHistory returns one page of a player's matches, newest first
+func (mhs *MatchHistoryService) GetHistory(req MatchHistoryRequest) (*MatchHistoryResponse, error) {
+	query := store.PlayerMatchQuery{
+		PlayerId: req.PlayerId,
+		GameId:   req.GameId,
+		Result:   req.Result,
+		Limit:    req.Limit,
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultHistoryLimit
+	}
+	query.Limit = min(query.Limit, MaxHistoryLimit)
+
+	switch req.Result {
+	case "", store.ResultWin, store.ResultLoss, store.ResultUnfinished:
+	default:
+		return nil, fmt.Errorf("%w: unknown result %q", ErrInvalidHistoryQuery, req.Result)
+	}
+
+	var err error
+	if query.From, err = parseHistoryDate(req.From); err != nil {
+		return nil, err
+	}
+	if query.To, err = parseHistoryDate(req.To); err != nil {
+		return nil, err
+	}
+	if req.Cursor != "" {
+		cursor, err := decodeHistoryCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.After = cursor
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query.Limit++
+	matches, err := mhs.store.ListPlayerMatches(query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &MatchHistoryResponse{
+		PlayerId: req.PlayerId,
+		Matches:  make([]MatchHistoryEntry, 0, len(matches)),
+	}
+	if len(matches) == query.Limit {
+		matches = matches[:len(matches)-1]
+		resp.NextCursor = encodeHistoryCursor(matches[len(matches)-1].Cursor)
+	}
+	for _, pm := range matches {
+		resp.Matches = append(resp.Matches, mapToMatchHistoryEntry(pm))
+	}
+	return resp, nil
+}
+
+// mapToMatchHistoryEntry helper
+func mapToMatchHistoryEntry(pm store.PlayerMatch) MatchHistoryEntry {
+	entry := MatchHistoryEntry{
+		MatchId:     pm.MatchId,
+		GameId:      pm.GameId,
+		CreatedAt:   pm.CreatedAt,
+		Result:      pm.Player.Result(),
+		FinalScore:  pm.Player.FinalScore,
+		Placement:   pm.Player.Placement,
+		DeathReason: pm.Player.DeathReason,
+		Opponents:   pm.Opponents,
+	}
+	if !pm.StartedAt.IsZero() {
+		entry.StartedAt = &pm.StartedAt
+	}
+	if !pm.EndedAt.IsZero() {
+		entry.EndedAt = &pm.EndedAt
+	}
+	if entry.StartedAt != nil && entry.EndedAt != nil {
+		duration := int64(pm.EndedAt.Sub(pm.StartedAt).Seconds())
+		entry.DurationSeconds = &duration
+	}
+	return entry
+}
+
+func parseHistoryDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: invalid date %q", ErrInvalidHistoryQuery, value)
+}
+
+// Cursors are opaque to clients: base64 of "createdAt:seq"
+func encodeHistoryCursor(c store.MatchCursor) string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt, c.Seq)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(value string) (*store.MatchCursor, error) {
+	invalid := fmt.Errorf("%w: invalid cursor", ErrInvalidHistoryQuery)
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalid
+	}
+	createdAt, seq, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, invalid
+	}
+
+	var cursor store.MatchCursor
+	if cursor.CreatedAt, err = strconv.ParseInt(createdAt, 10, 64); err != nil {
+		return nil, invalid
+	}
+	if cursor.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil {
+		return nil, invalid
+	}
+	return &cursor, nil
+}
@@ -1,14 +1,16 @@
 package service
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
+	"game-server/internal/events"
+	"game-server/internal/store"
 	"log"
-	"strings"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 var (
@@ -26,53 +28,49 @@ const (
 	StatusQueued   = "queued"
 	StatusInMatch  = "in_match"
 	StatusIdle     = "idle"
+	// StatusOffered is only reported by GetMatch, offered players stay queued in the store
+	StatusOffered  = "offered"
 )
 
+// MatchOfferTimeout is how long players have to accept a match offer
+const MatchOfferTimeout = 20 * time.Second
+
+var ErrNoMatchOffer = errors.New("player has no pending match offer")
+
 type MatchMakeService struct {
-	queue map[string]string // playerId -> gameId
-	db    *sql.DB
-	mu    sync.RWMutex
+	queue        map[string]string      // playerId -> gameId
+	offers       map[string]*matchOffer // playerId -> pending offer
+	store        store.MatchStore
+	penalties    *PenaltyService
+	bus          *events.Bus
+	mu           sync.RWMutex
+	offerTimeout time.Duration
 }
 
-type GameEnv struct {
-	GameId  string   `json:"gameId"`
-	MatchId string   `json:"matchId"`
-	Players []string `json:"players"`
+// matchOffer is the ready check of a match, which is only created once every
+// player accepted it
+type matchOffer struct {
+	match    GameEnv
+	accepted map[string]bool
+	timer    *time.Timer
 }
 
+type GameEnv = store.Match
+
 type PlayerMatchResponse struct {
 	PlayerId string  `json:"playerId"`
 	GameEnv  GameEnv `json:"gameEnv"`
 	Status   string  `json:"status"`
 }
 
-func NewMatchMakeService() *MatchMakeService {
-	db, err := sql.Open("sqlite3", "./matches.db")
-	if err != nil {
-		log.Fatalf("Failed to open DB: %v", err)
-	}
-
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS matches (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			matchId TEXT UNIQUE,
-			gameId TEXT,
-			players TEXT
-		);
-		CREATE TABLE IF NOT EXISTS playerStatus (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			playerId TEXT UNIQUE,
-			status TEXT,
-			matchId TEXT
-		)
-	`)
-	if err != nil {
-		log.Fatalf("Failed to create table: %v", err)
-	}
-
+func NewMatchMakeService(matchStore store.MatchStore, penalties *PenaltyService, bus *events.Bus) *MatchMakeService {
 	return &MatchMakeService{
-		queue: make(map[string]string),
-		db:    db,
+		queue:        make(map[string]string),
+		offers:       make(map[string]*matchOffer),
+		store:        matchStore,
+		penalties:    penalties,
+		bus:          bus,
+		offerTimeout: MatchOfferTimeout,
 	}
 }
 
@@ -81,8 +79,8 @@ func (ms *MatchMakeService) AddQueue(playerId string, gameId string) error {
 	defer ms.mu.Unlock()
 
 	// Check current player status
-	status, matchId, err := ms.getPlayerStatus(playerId)
-	if err != nil && err != sql.ErrNoRows {
+	status, matchId, err := ms.store.GetPlayerStatus(playerId)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
 		return fmt.Errorf("error checking player status: %v", err)
 	}
 
@@ -95,12 +93,20 @@ func (ms *MatchMakeService) AddQueue(playerId string, gameId string) error {
 	if _, exists := ms.queue[playerId]; exists {
 		return fmt.Errorf("player already in queue with game id %v", ms.queue[playerId])
 	}
+	if _, offered := ms.offers[playerId]; offered {
+		return fmt.Errorf("player already has a pending match offer")
+	}
+
+	// Players with recent dodges or abandons have to wait out their cooldown
+	if err := ms.penalties.CheckCooldown(playerId); err != nil {
+		return err
+	}
 
 	// Add to queue
 	ms.queue[playerId] = gameId
 	
 	// Update player status to queued
-	if err := ms.updatePlayerStatus(playerId, StatusQueued, ""); err != nil {
+	if err := ms.store.UpdatePlayerStatus(playerId, StatusQueued, ""); err != nil {
 		delete(ms.queue, playerId)
 		return fmt.Errorf("failed to update player status: %v", err)
 	}
@@ -116,6 +122,13 @@ func (ms *MatchMakeService) RemoveQueue(playerId string) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	// Leaving with a match on offer declines it
+	if offer, ok := ms.offers[playerId]; ok {
+		ms.cancelOffer(offer, []string{playerId})
+		log.Printf("%v removed from the queue, declining match %v", playerId, offer.match.MatchId)
+		return nil
+	}
+
 	if _, exists := ms.queue[playerId]; !exists {
 		return fmt.Errorf("player %v not found in queue", playerId)
 	}
@@ -123,7 +136,7 @@ func (ms *MatchMakeService) RemoveQueue(playerId string) error {
 	delete(ms.queue, playerId)
 	
 	// Update player status to idle
-	if err := ms.updatePlayerStatus(playerId, StatusIdle, ""); err != nil {
+	if err := ms.store.UpdatePlayerStatus(playerId, StatusIdle, ""); err != nil {
 		log.Printf("Failed to update player status to idle: %v", err)
 	}
 
@@ -131,14 +144,75 @@ func (ms *MatchMakeService) RemoveQueue(playerId string) error {
 	return nil
 }
 
+// AcceptMatch accepts the player's match offer, the match is created once
+// every player accepted
+func (ms *MatchMakeService) AcceptMatch(playerId string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	offer, ok := ms.offers[playerId]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrNoMatchOffer, playerId)
+	}
+	offer.accepted[playerId] = true
+	log.Printf("Player %v accepted match %v", playerId, offer.match.MatchId)
+
+	if len(offer.accepted) < len(offer.match.Players) {
+		return nil
+	}
+	offer.timer.Stop()
+	ms.settleOffer(offer)
+	ms.createMatch(offer.match)
+	return nil
+}
+
+// DeclineMatch declines the player's match offer, which counts as a dodge.
+// The other players go back to the queue.
+func (ms *MatchMakeService) DeclineMatch(playerId string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	offer, ok := ms.offers[playerId]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrNoMatchOffer, playerId)
+	}
+	ms.cancelOffer(offer, []string{playerId})
+	log.Printf("Player %v declined match %v", playerId, offer.match.MatchId)
+	return nil
+}
+
+// AbandonMatch records a player leaving a match that is still in progress
+func (ms *MatchMakeService) AbandonMatch(e events.Event) {
+	log.Printf("Player %v abandoned match %v", e.PlayerId, e.MatchId)
+	if err := ms.penalties.RecordAbandon(e.PlayerId); err != nil {
+		log.Printf("Failed to record abandon for %v: %v", e.PlayerId, err)
+	}
+}
+
+// FinishMatch frees the players of a match the game server has ended
+func (ms *MatchMakeService) FinishMatch(e events.Event) {
+	if err := ms.EndMatch(e.MatchId); err != nil {
+		log.Printf("Failed to end match %v: %v", e.MatchId, err)
+	}
+}
+
 func (ms *MatchMakeService) GetMatch(playerId string) (*PlayerMatchResponse, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
+	// Offers are only kept in memory until every player accepted
+	if offer, ok := ms.offers[playerId]; ok {
+		return &PlayerMatchResponse{
+			PlayerId: playerId,
+			GameEnv:  offer.match,
+			Status:   StatusOffered,
+		}, nil
+	}
+
 	// Check player status in DB
-	status, matchId, err := ms.getPlayerStatus(playerId)
+	status, matchId, err := ms.store.GetPlayerStatus(playerId)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			return nil, fmt.Errorf("player %v has no active match", playerId)
 		}
 		return nil, fmt.Errorf("error getting player status: %v", err)
@@ -146,7 +220,7 @@ func (ms *MatchMakeService) GetMatch(playerId string) (*PlayerMatchResponse, err
 
 	// If player is in match, load from DB
 	if status == StatusInMatch && matchId != "" {
-		gameEnv, err := ms.loadMatchFromDB(matchId)
+		gameEnv, err := ms.store.LoadMatch(matchId)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load match from DB: %v", err)
 		}
@@ -167,14 +241,14 @@ func (ms *MatchMakeService) EndMatch(matchId string) error {
 	defer ms.mu.Unlock()
 
 	// Load match from DB to get all players
-	gameEnv, err := ms.loadMatchFromDB(matchId)
+	gameEnv, err := ms.store.LoadMatch(matchId)
 	if err != nil {
 		return fmt.Errorf("failed to load match: %v", err)
 	}
 
 	// Update all players' status to idle
 	for _, playerId := range gameEnv.Players {
-		if err := ms.updatePlayerStatus(playerId, StatusIdle, ""); err != nil {
+		if err := ms.store.UpdatePlayerStatus(playerId, StatusIdle, ""); err != nil {
 			log.Printf("Failed to update player %v status to idle: %v", playerId, err)
 		}
 	}
@@ -201,93 +275,108 @@ func (ms *MatchMakeService) matchMake(gameId string) {
 	if len(players) >= requiredPlayers {
 		// Take only the required number of players
 		selectedPlayers := players[:requiredPlayers]
-		
-		matchId := fmt.Sprintf("match-%v", uuid.New())
-		log.Printf("Creating match %v for game %v with players: %v", matchId, gameId, selectedPlayers)
-
-		gameEnv := GameEnv{
-			GameId:  gameId,
-			MatchId: matchId,
-			Players: selectedPlayers,
+		for _, p := range selectedPlayers {
+			delete(ms.queue, p)
 		}
 
-		if err := ms.saveMatchToDB(gameEnv); err != nil {
-			log.Printf("Error saving match to DB: %v", err)
+		match := GameEnv{
+			GameId:    gameId,
+			MatchId:   fmt.Sprintf("match-%v", uuid.New()),
+			Players:   selectedPlayers,
+			CreatedAt: time.Now(),
+		}
+		// A ready check only matters when there are others to wait for
+		if requiredPlayers == 1 {
+			ms.createMatch(match)
 			return
 		}
+		ms.offerMatch(match)
+	}
+}
 
-		// Remove players from queue and update their status
-		for _, p := range selectedPlayers {
-			delete(ms.queue, p)
-			
-			// Update player status to in_match
-			if err := ms.updatePlayerStatus(p, StatusInMatch, matchId); err != nil {
-				log.Printf("Failed to update player %v status: %v", p, err)
-			}
-		}
-		
-		log.Printf("Match %v created successfully", matchId)
+// offerMatch starts the ready check of a match, players that haven't
+// accepted it when it times out dodged it
+func (ms *MatchMakeService) offerMatch(match GameEnv) {
+	offer := &matchOffer{match: match, accepted: make(map[string]bool)}
+	for _, p := range match.Players {
+		ms.offers[p] = offer
 	}
+	offer.timer = time.AfterFunc(ms.offerTimeout, func() { ms.expireOffer(offer) })
+	log.Printf("Offering match %v for game %v to players: %v", match.MatchId, match.GameId, match.Players)
 }
 
-func (ms *MatchMakeService) saveMatchToDB(env GameEnv) error {
-	playerList := strings.Join(env.Players, ",")
-	_, err := ms.db.Exec(`
-		INSERT INTO matches (matchId, gameId, players)
-		VALUES (?, ?, ?)
-	`, env.MatchId, env.GameId, playerList)
+func (ms *MatchMakeService) expireOffer(offer *matchOffer) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 
-	if err != nil {
-		return fmt.Errorf("failed to insert match: %v", err)
+	// accepted or declined in the meantime
+	if ms.offers[offer.match.Players[0]] != offer {
+		return
 	}
-	return nil
+	var dodgers []string
+	for _, p := range offer.match.Players {
+		if !offer.accepted[p] {
+			dodgers = append(dodgers, p)
+		}
+	}
+	log.Printf("Match offer %v timed out waiting for: %v", offer.match.MatchId, dodgers)
+	ms.cancelOffer(offer, dodgers)
 }
 
-func (ms *MatchMakeService) loadMatchFromDB(matchId string) (*GameEnv, error) {
-	var gameId, playerList string
-	err := ms.db.QueryRow(`
-		SELECT gameId, players FROM matches WHERE matchId = ?
-	`, matchId).Scan(&gameId, &playerList)
+// cancelOffer drops an offer, penalizes the players that dodged it and puts
+// everyone else back in the queue
+func (ms *MatchMakeService) cancelOffer(offer *matchOffer, dodgers []string) {
+	offer.timer.Stop()
+	ms.settleOffer(offer)
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to load match: %v", err)
+	for _, p := range offer.match.Players {
+		if !slices.Contains(dodgers, p) {
+			ms.queue[p] = offer.match.GameId
+			continue
+		}
+		if err := ms.store.UpdatePlayerStatus(p, StatusIdle, ""); err != nil {
+			log.Printf("Failed to update player %v status to idle: %v", p, err)
+		}
+		if err := ms.penalties.RecordDodge(p); err != nil {
+			log.Printf("Failed to record queue dodge for %v: %v", p, err)
+		}
 	}
-
-	players := strings.Split(playerList, ",")
-	return &GameEnv{
-		GameId:  gameId,
-		MatchId: matchId,
-		Players: players,
-	}, nil
+	ms.matchMake(offer.match.GameId)
 }
 
-func (ms *MatchMakeService) getPlayerStatus(playerId string) (string, string, error) {
-	var status, matchId string
-	err := ms.db.QueryRow(`
-		SELECT status, matchId FROM playerStatus WHERE playerId = ?
-	`, playerId).Scan(&status, &matchId)
-
-	return status, matchId, err
+func (ms *MatchMakeService) settleOffer(offer *matchOffer) {
+	for _, p := range offer.match.Players {
+		delete(ms.offers, p)
+	}
 }
 
-func (ms *MatchMakeService) updatePlayerStatus(playerId, status, matchId string) error {
-	_, err := ms.db.Exec(`
-		INSERT INTO playerStatus (playerId, status, matchId)
-		VALUES (?, ?, ?)
-		ON CONFLICT(playerId) DO UPDATE SET
-			status = excluded.status,
-			matchId = excluded.matchId
-	`, playerId, status, matchId)
+// createMatch saves the match and moves its players into it
+func (ms *MatchMakeService) createMatch(gameEnv GameEnv) {
+	matchId, gameId, selectedPlayers := gameEnv.MatchId, gameEnv.GameId, gameEnv.Players
+	log.Printf("Creating match %v for game %v with players: %v", matchId, gameId, selectedPlayers)
 
-	if err != nil {
-		return fmt.Errorf("failed to update player status: %v", err)
+	if err := ms.store.SaveMatch(gameEnv); err != nil {
+		log.Printf("Error saving match to DB: %v", err)
+		// back to the queue for the next attempt
+		for _, p := range selectedPlayers {
+			ms.queue[p] = gameId
+		}
+		return
 	}
-	return nil
-}
 
-func (ms *MatchMakeService) Close() error {
-	if ms.db != nil {
-		return ms.db.Close()
+	// Update the status of the players
+	for _, p := range selectedPlayers {
+		// Update player status to in_match
+		if err := ms.store.UpdatePlayerStatus(p, StatusInMatch, matchId); err != nil {
+			log.Printf("Failed to update player %v status: %v", p, err)
+		}
 	}
-	return nil
-}
\ No newline at end of file
+
+	log.Printf("Match %v created successfully", matchId)
+	ms.bus.Publish(events.Event{
+		Type:    events.MatchCreated,
+		MatchId: matchId,
+		GameId:  gameId,
+		Players: selectedPlayers,
+	})
+}
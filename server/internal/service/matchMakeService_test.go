@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"game-server/internal/events"
+	"game-server/internal/store"
+)
+
+func newTestMatchMaker() (*MatchMakeService, *store.MemoryStore) {
+	s := store.NewMemoryStore()
+	return NewMatchMakeService(s, NewPenaltyService(s), events.NewBus()), s
+}
+
+func TestMatchMakeFormsMatch(t *testing.T) {
+	ms, s := newTestMatchMaker()
+
+	if err := ms.AddQueue("a", "snake"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _, _ := s.GetPlayerStatus("a"); status != StatusQueued {
+		t.Fatalf("status after queueing = %q, want %q", status, StatusQueued)
+	}
+	if _, err := ms.GetMatch("a"); err == nil {
+		t.Fatal("GetMatch found a match for a lone queued player")
+	}
+	if err := ms.AddQueue("a", "snake"); err == nil {
+		t.Fatal("queueing twice succeeded")
+	}
+
+	if err := ms.AddQueue("b", "snake"); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := ms.GetMatch("a")
+	if err != nil || offer.Status != StatusOffered {
+		t.Fatalf("a's match once b queued = %+v, %v, want an offer", offer, err)
+	}
+	for _, playerId := range []string{"a", "b"} {
+		if err := ms.AcceptMatch(playerId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ms.AcceptMatch("a"); !errors.Is(err, ErrNoMatchOffer) {
+		t.Fatalf("accepting a created match = %v, want ErrNoMatchOffer", err)
+	}
+
+	resp, err := ms.GetMatch("a")
+	if err != nil || resp.Status != StatusInMatch || resp.GameEnv.MatchId != offer.GameEnv.MatchId {
+		t.Fatalf("a's match after accepting = %+v, %v, want the offered match", resp, err)
+	}
+	match := resp.GameEnv
+	if match.GameId != "snake" || len(match.Players) != 2 || !slices.Contains(match.Players, "a") || !slices.Contains(match.Players, "b") {
+		t.Fatalf("match = %+v", match)
+	}
+	other, err := ms.GetMatch("b")
+	if err != nil || other.GameEnv.MatchId != match.MatchId {
+		t.Fatalf("b's match = %+v, %v, want %s", other, err, match.MatchId)
+	}
+	if len(ms.queue) != 0 {
+		t.Fatalf("queue after matching = %v, want empty", ms.queue)
+	}
+
+	if err := ms.AddQueue("a", "snake"); err == nil {
+		t.Fatal("queueing while in a match succeeded")
+	}
+
+	if err := ms.EndMatch(match.MatchId); err != nil {
+		t.Fatal(err)
+	}
+	for _, playerId := range match.Players {
+		if status, _, _ := s.GetPlayerStatus(playerId); status != StatusIdle {
+			t.Fatalf("status of %s after the match = %q, want %q", playerId, status, StatusIdle)
+		}
+	}
+	if err := ms.AddQueue("a", "snake"); err != nil {
+		t.Fatalf("queueing after the match = %v", err)
+	}
+}
+
+func TestMatchMakeKeepsGamesApart(t *testing.T) {
+	ms, _ := newTestMatchMaker()
+
+	for playerId, gameId := range map[string]string{"a": "snake", "b": "tic-tac-toe", "c": "random-snake-game", "d": "random-snake-game"} {
+		if err := ms.AddQueue(playerId, gameId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ms.queue) != 4 {
+		t.Fatalf("queue = %v, want every player still waiting", ms.queue)
+	}
+
+	if err := ms.AddQueue("e", "single-snake-game"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ms.GetMatch("e")
+	if err != nil || !slices.Equal(resp.GameEnv.Players, []string{"e"}) {
+		t.Fatalf("single player match = %+v, %v", resp, err)
+	}
+}
+
+func TestMatchMakeDodgeCooldown(t *testing.T) {
+	ms, s := newTestMatchMaker()
+
+	if err := ms.RemoveQueue("a"); err == nil {
+		t.Fatal("leaving the queue without being queued succeeded")
+	}
+
+	// leaving the queue before a match is offered is free
+	if err := ms.AddQueue("a", "snake"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.RemoveQueue("a"); err != nil {
+		t.Fatal(err)
+	}
+	if penalty, _ := ms.penalties.GetPenalty("a"); penalty.Dodges != 0 {
+		t.Fatalf("penalty after leaving the queue = %+v, want no dodge", penalty)
+	}
+
+	// every declined offer is a point, the third starts a cooldown. b is
+	// put back in the queue each time.
+	if err := ms.AddQueue("b", "snake"); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := ms.AddQueue("a", "snake"); err != nil {
+			t.Fatal(err)
+		}
+		decline := ms.DeclineMatch
+		if i == 2 {
+			// leaving the queue with a match on offer declines it
+			decline = ms.RemoveQueue
+		}
+		if err := decline("a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status, _, _ := s.GetPlayerStatus("a"); status != StatusIdle {
+		t.Fatalf("status after declining = %q, want %q", status, StatusIdle)
+	}
+	if err := ms.AddQueue("a", "snake"); !errors.Is(err, ErrQueueCooldown) {
+		t.Fatalf("queueing after 3 dodges = %v, want ErrQueueCooldown", err)
+	}
+	if penalty, _ := ms.penalties.GetPenalty("b"); penalty.Dodges != 0 || ms.queue["b"] != "snake" {
+		t.Fatalf("b after a declined = %+v, queued for %q, want no dodge and still queued", penalty, ms.queue["b"])
+	}
+}
+
+func TestMatchMakeOfferTimeout(t *testing.T) {
+	ms, s := newTestMatchMaker()
+	ms.offerTimeout = 20 * time.Millisecond
+
+	for _, playerId := range []string{"a", "b"} {
+		if err := ms.AddQueue(playerId, "snake"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ms.AcceptMatch("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// b never answers, so b dodged and a waits for the next match
+	deadline := time.After(time.Second)
+	for {
+		if _, err := ms.GetMatch("b"); err != nil {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("the offer did not time out")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if status, _, _ := s.GetPlayerStatus("b"); status != StatusIdle {
+		t.Fatalf("b's status = %q, want %q", status, StatusIdle)
+	}
+	if penalty, _ := ms.penalties.GetPenalty("b"); penalty.Dodges != 1 {
+		t.Fatalf("b's penalty = %+v, want a dodge", penalty)
+	}
+	if penalty, _ := ms.penalties.GetPenalty("a"); penalty.Dodges != 0 {
+		t.Fatalf("a's penalty = %+v, want none", penalty)
+	}
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if ms.queue["a"] != "snake" || len(ms.offers) != 0 {
+		t.Fatalf("queue = %v, offers = %v, want a queued again", ms.queue, ms.offers)
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"game-server/internal/store"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Penalty points added per offence
+	AbandonPenaltyPoints = 2
+	DodgePenaltyPoints   = 1
+
+	// One penalty point is forgiven for every PenaltyDecayInterval without a new offence
+	PenaltyDecayInterval = 30 * time.Minute
+)
+
+// Queue cooldowns by penalty points, checked from the highest threshold down
+var penaltyCooldowns = []struct {
+	Points   int
+	Cooldown time.Duration
+}{
+	{Points: 10, Cooldown: 60 * time.Minute},
+	{Points: 7, Cooldown: 15 * time.Minute},
+	{Points: 5, Cooldown: 5 * time.Minute},
+	{Points: 3, Cooldown: 1 * time.Minute},
+}
+
+var ErrQueueCooldown = errors.New("player is on queue cooldown")
+
+type PlayerPenalty = store.PlayerPenalty
+
+type PenaltyService struct {
+	store store.PenaltyStore
+	mu    sync.Mutex
+}
+
+func NewPenaltyService(penaltyStore store.PenaltyStore) *PenaltyService {
+	return &PenaltyService{
+		store: penaltyStore,
+	}
+}
+
+// RecordAbandon penalizes a player who left a match that was still running
+func (ps *PenaltyService) RecordAbandon(playerId string) error {
+	return ps.recordOffense(playerId, func(p *PlayerPenalty) {
+		p.Abandons++
+		p.Points += AbandonPenaltyPoints
+	})
+}
+
+// RecordDodge penalizes a player who declined a match offer or let it time out
+func (ps *PenaltyService) RecordDodge(playerId string) error {
+	return ps.recordOffense(playerId, func(p *PlayerPenalty) {
+		p.Dodges++
+		p.Points += DodgePenaltyPoints
+	})
+}
+
+// CheckCooldown returns ErrQueueCooldown if the player is not allowed to queue yet
+func (ps *PenaltyService) CheckCooldown(playerId string) error {
+	penalty, err := ps.GetPenalty(playerId)
+	if err != nil {
+		return err
+	}
+
+	remaining := time.Until(penalty.CooldownUntil)
+	if remaining > 0 {
+		return fmt.Errorf("%w for %v", ErrQueueCooldown, remaining.Round(time.Second))
+	}
+	return nil
+}
+
+// GetPenalty returns the player's penalty record with decay applied
+func (ps *PenaltyService) GetPenalty(playerId string) (*PlayerPenalty, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	penalty, err := ps.loadPenalty(playerId)
+	if err != nil {
+		return nil, err
+	}
+	decayPenalty(penalty, time.Now())
+	return penalty, nil
+}
+
+// ListPenalties returns every player that currently has penalty points or a cooldown
+func (ps *PenaltyService) ListPenalties() ([]*PlayerPenalty, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	stored, err := ps.store.ListPenalties()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	penalties := make([]*PlayerPenalty, 0)
+	for _, penalty := range stored {
+		decayPenalty(&penalty, now)
+		if penalty.Points > 0 || penalty.CooldownUntil.After(now) {
+			penalties = append(penalties, &penalty)
+		}
+	}
+	return penalties, nil
+}
+
+// ClearPenalty removes all penalty history for a player
+func (ps *PenaltyService) ClearPenalty(playerId string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if err := ps.store.DeletePenalty(playerId); err != nil {
+		return fmt.Errorf("failed to clear penalty: %v", err)
+	}
+	log.Printf("Penalties cleared for player %v", playerId)
+	return nil
+}
+
+func (ps *PenaltyService) recordOffense(playerId string, apply func(p *PlayerPenalty)) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	penalty, err := ps.loadPenalty(playerId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	decayPenalty(penalty, now)
+	apply(penalty)
+	penalty.LastOffenseAt = now
+	if cooldown := cooldownFor(penalty.Points); cooldown > 0 {
+		penalty.CooldownUntil = now.Add(cooldown)
+	}
+
+	if err := ps.store.SavePenalty(*penalty); err != nil {
+		return err
+	}
+	log.Printf("Player %v penalized: %d points, cooldown until %v", playerId, penalty.Points, penalty.CooldownUntil)
+	return nil
+}
+
+func (ps *PenaltyService) loadPenalty(playerId string) (*PlayerPenalty, error) {
+	penalty, err := ps.store.GetPenalty(playerId)
+	if errors.Is(err, store.ErrNotFound) {
+		return &PlayerPenalty{PlayerId: playerId}, nil
+	}
+	return penalty, err
+}
+
+// decayPenalty forgives one point per full decay interval since the last offence.
+// The stored value is only rewritten on the next offence.
+func decayPenalty(p *PlayerPenalty, now time.Time) {
+	if p.Points == 0 || p.LastOffenseAt.IsZero() {
+		return
+	}
+	decayed := int(now.Sub(p.LastOffenseAt) / PenaltyDecayInterval)
+	p.Points = max(p.Points-decayed, 0)
+}
+
+func cooldownFor(points int) time.Duration {
+	for _, c := range penaltyCooldowns {
+		if points >= c.Points {
+			return c.Cooldown
+		}
+	}
+	return 0
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"game-server/internal/store"
+)
+
+func TestDecayPenalty(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		points    int
+		sinceLast time.Duration
+		want      int
+	}{
+		{"fresh offence", 3, time.Minute, 3},
+		{"just under one interval", 3, PenaltyDecayInterval - time.Second, 3},
+		{"one interval", 3, PenaltyDecayInterval, 2},
+		{"two and a half intervals", 3, 5 * PenaltyDecayInterval / 2, 1},
+		{"never below zero", 2, 10 * PenaltyDecayInterval, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := PlayerPenalty{Points: tt.points, LastOffenseAt: now.Add(-tt.sinceLast)}
+			decayPenalty(&p, now)
+			if p.Points != tt.want {
+				t.Fatalf("points = %d, want %d", p.Points, tt.want)
+			}
+		})
+	}
+}
+
+func TestCooldownFor(t *testing.T) {
+	tests := []struct {
+		points int
+		want   time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, time.Minute},
+		{5, 5 * time.Minute},
+		{7, 15 * time.Minute},
+		{10, 60 * time.Minute},
+		{25, 60 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := cooldownFor(tt.points); got != tt.want {
+			t.Errorf("cooldownFor(%d) = %v, want %v", tt.points, got, tt.want)
+		}
+	}
+}
+
+func TestPenaltyCooldown(t *testing.T) {
+	ps := NewPenaltyService(store.NewMemoryStore())
+
+	// two dodges stay below the first cooldown threshold
+	for range 2 {
+		if err := ps.RecordDodge("a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ps.CheckCooldown("a"); err != nil {
+		t.Fatalf("CheckCooldown after 2 points = %v, want nil", err)
+	}
+
+	if err := ps.RecordAbandon("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.CheckCooldown("a"); !errors.Is(err, ErrQueueCooldown) {
+		t.Fatalf("CheckCooldown after 4 points = %v, want ErrQueueCooldown", err)
+	}
+
+	p, err := ps.GetPenalty("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Points != 4 || p.Dodges != 2 || p.Abandons != 1 {
+		t.Fatalf("penalty = %+v", p)
+	}
+	if remaining := time.Until(p.CooldownUntil); remaining <= 0 || remaining > time.Minute {
+		t.Fatalf("cooldown remaining = %v, want up to a minute", remaining)
+	}
+
+	if err := ps.ClearPenalty("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.CheckCooldown("a"); err != nil {
+		t.Fatalf("CheckCooldown after clearing = %v, want nil", err)
+	}
+}
+
+func TestPenaltyDecaysBeforeNextOffence(t *testing.T) {
+	penalties := store.NewMemoryStore()
+	ps := NewPenaltyService(penalties)
+
+	last := time.Now().Add(-2*PenaltyDecayInterval - time.Minute)
+	err := penalties.SavePenalty(PlayerPenalty{PlayerId: "a", Dodges: 4, Points: 4, LastOffenseAt: last, CooldownUntil: last.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ps.GetPenalty("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Points != 2 {
+		t.Fatalf("decayed points = %d, want 2", p.Points)
+	}
+	if err := ps.CheckCooldown("a"); err != nil {
+		t.Fatalf("CheckCooldown after an expired cooldown = %v, want nil", err)
+	}
+
+	// the next offence builds on the decayed points
+	if err := ps.RecordDodge("a"); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := ps.GetPenalty("a"); p.Points != 3 {
+		t.Fatalf("points after another dodge = %d, want 3", p.Points)
+	}
+	if err := ps.CheckCooldown("a"); !errors.Is(err, ErrQueueCooldown) {
+		t.Fatalf("CheckCooldown at 3 points = %v, want ErrQueueCooldown", err)
+	}
+
+	listed, err := ps.ListPenalties()
+	if err != nil || len(listed) != 1 || listed[0].PlayerId != "a" {
+		t.Fatalf("ListPenalties = %+v, %v", listed, err)
+	}
+}
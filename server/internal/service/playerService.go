@@ -120,6 +120,19 @@ func (ps *PlayerService) Signup(request SignupRequest) (*PlayerResponse, error)
 	return mapToPlayerResponse(newPlayer), nil
 }
 
+// Username returns the name a player signed up with, empty if the id is unknown
+func (ps *PlayerService) Username(userId string) string {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	for _, player := range ps.Players {
+		if player.UserId == userId {
+			return player.Username
+		}
+	}
+	return ""
+}
+
 // mapToPlayerResponse helper
 func mapToPlayerResponse(player *Player) *PlayerResponse {
 	return &PlayerResponse{
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"game-server/internal/store"
+	"slices"
+	"strings"
+)
+
+type PlayerStatsService struct {
+	store store.StatsStore
+}
+
+func NewPlayerStatsService(statsStore store.StatsStore) *PlayerStatsService {
+	return &PlayerStatsService{
+		store: statsStore,
+	}
+}
+
+// GetSnakeStats aggregates the player's finished matches across all snake modes
+func (pss *PlayerStatsService) GetSnakeStats(playerId string) (*store.SnakeStats, error) {
+	return pss.store.SnakeStats(playerId, snakeGameIds())
+}
+
+// snakeGameIds lists every game mode played on the snake engine
+func snakeGameIds() []string {
+	gameIds := make([]string, 0)
+	for gameId := range GamePlayerRequirements {
+		if strings.Contains(gameId, "snake") {
+			gameIds = append(gameIds, gameId)
+		}
+	}
+	slices.Sort(gameIds)
+	return gameIds
+}
@@ -0,0 +1,136 @@
+package snake
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// DeathModeRemove takes dead snakes off the board straight away
+	DeathModeRemove = "remove"
+	// DeathModeFood turns every segment of a dead snake into a food pellet
+	DeathModeFood = "food"
+	// DeathModeCorpse leaves the body as an obstacle for CorpseTicks ticks
+	DeathModeCorpse = "corpse"
+
+	DefaultCorpseTicks = 30
+
+	// Connections are pinged every PingInterval and dropped when nothing,
+	// not even a pong, arrives within PongTimeout
+	DefaultPingInterval   = 10 * time.Second
+	DefaultPongTimeout    = 30 * time.Second
+	DefaultWriteTimeout   = 2 * time.Second
+	DefaultMaxMessageSize = 4096
+
+	// DefaultReconnectGrace is how long a dropped player has to come back
+	DefaultReconnectGrace = 15 * time.Second
+
+	// DefaultSpectatorDelay holds the board back from spectators so they
+	// can't call out positions to a player
+	DefaultSpectatorDelay = 3 * time.Second
+)
+
+// Config holds the snake rules that can be changed per deployment
+type Config struct {
+	DeathMode   string
+	CorpseTicks int
+
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+	ReconnectGrace time.Duration
+
+	SpectatorDelay time.Duration
+	SpectatorChat  bool
+}
+
+// ConfigFromEnv reads SNAKE_DEATH_MODE and SNAKE_CORPSE_TICKS, defaulting to
+// a corpse that fades after DefaultCorpseTicks ticks, and the connection
+// settings SNAKE_PING_INTERVAL, SNAKE_PONG_TIMEOUT, SNAKE_WRITE_TIMEOUT,
+// SNAKE_MAX_MESSAGE_SIZE and SNAKE_RECONNECT_GRACE, and the spectator
+// settings SNAKE_SPECTATOR_DELAY and SNAKE_SPECTATOR_CHAT
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		DeathMode:      os.Getenv("SNAKE_DEATH_MODE"),
+		CorpseTicks:    DefaultCorpseTicks,
+		PingInterval:   DefaultPingInterval,
+		PongTimeout:    DefaultPongTimeout,
+		WriteTimeout:   DefaultWriteTimeout,
+		MaxMessageSize: DefaultMaxMessageSize,
+		ReconnectGrace: DefaultReconnectGrace,
+		SpectatorDelay: DefaultSpectatorDelay,
+		SpectatorChat:  true,
+	}
+	if cfg.DeathMode == "" {
+		cfg.DeathMode = DeathModeCorpse
+	}
+
+	switch cfg.DeathMode {
+	case DeathModeRemove, DeathModeFood, DeathModeCorpse:
+	default:
+		return cfg, fmt.Errorf("unknown snake death mode %q", cfg.DeathMode)
+	}
+
+	if ticks := os.Getenv("SNAKE_CORPSE_TICKS"); ticks != "" {
+		n, err := strconv.Atoi(ticks)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("SNAKE_CORPSE_TICKS must be a positive number of ticks, got %q", ticks)
+		}
+		cfg.CorpseTicks = n
+	}
+
+	for name, d := range map[string]*time.Duration{
+		"SNAKE_PING_INTERVAL": &cfg.PingInterval,
+		"SNAKE_PONG_TIMEOUT":  &cfg.PongTimeout,
+		"SNAKE_WRITE_TIMEOUT": &cfg.WriteTimeout,
+	} {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				return cfg, fmt.Errorf("%s must be a positive duration like 10s, got %q", name, value)
+			}
+			*d = parsed
+		}
+	}
+	if cfg.PongTimeout <= cfg.PingInterval {
+		return cfg, fmt.Errorf("SNAKE_PONG_TIMEOUT (%v) must be longer than SNAKE_PING_INTERVAL (%v)", cfg.PongTimeout, cfg.PingInterval)
+	}
+
+	if size := os.Getenv("SNAKE_MAX_MESSAGE_SIZE"); size != "" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("SNAKE_MAX_MESSAGE_SIZE must be a positive number of bytes, got %q", size)
+		}
+		cfg.MaxMessageSize = n
+	}
+
+	// a grace of 0s ends the match as soon as everyone is gone
+	if grace := os.Getenv("SNAKE_RECONNECT_GRACE"); grace != "" {
+		d, err := time.ParseDuration(grace)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("SNAKE_RECONNECT_GRACE must be a duration like 15s, got %q", grace)
+		}
+		cfg.ReconnectGrace = d
+	}
+
+	// a delay of 0s streams the match live
+	if delay := os.Getenv("SNAKE_SPECTATOR_DELAY"); delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("SNAKE_SPECTATOR_DELAY must be a duration like 3s, got %q", delay)
+		}
+		cfg.SpectatorDelay = d
+	}
+
+	if chat := os.Getenv("SNAKE_SPECTATOR_CHAT"); chat != "" {
+		b, err := strconv.ParseBool(chat)
+		if err != nil {
+			return cfg, fmt.Errorf("SNAKE_SPECTATOR_CHAT must be true or false, got %q", chat)
+		}
+		cfg.SpectatorChat = b
+	}
+	return cfg, nil
+}
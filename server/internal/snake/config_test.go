@@ -0,0 +1,72 @@
+package snake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	defaults := Config{
+		DeathMode:      DeathModeCorpse,
+		CorpseTicks:    DefaultCorpseTicks,
+		PingInterval:   DefaultPingInterval,
+		PongTimeout:    DefaultPongTimeout,
+		WriteTimeout:   DefaultWriteTimeout,
+		MaxMessageSize: DefaultMaxMessageSize,
+		ReconnectGrace: DefaultReconnectGrace,
+		SpectatorDelay: DefaultSpectatorDelay,
+		SpectatorChat:  true,
+	}
+	with := func(change func(cfg *Config)) Config {
+		cfg := defaults
+		change(&cfg)
+		return cfg
+	}
+
+	tests := []struct {
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{want: defaults},
+		{
+			env:  map[string]string{"SNAKE_DEATH_MODE": DeathModeFood, "SNAKE_CORPSE_TICKS": "5"},
+			want: with(func(cfg *Config) { cfg.DeathMode, cfg.CorpseTicks = DeathModeFood, 5 }),
+		},
+		{
+			env: map[string]string{"SNAKE_PING_INTERVAL": "1s", "SNAKE_PONG_TIMEOUT": "3s", "SNAKE_WRITE_TIMEOUT": "500ms", "SNAKE_MAX_MESSAGE_SIZE": "512"},
+			want: with(func(cfg *Config) {
+				cfg.PingInterval, cfg.PongTimeout, cfg.WriteTimeout, cfg.MaxMessageSize = time.Second, 3*time.Second, 500*time.Millisecond, 512
+			}),
+		},
+		{env: map[string]string{"SNAKE_DEATH_MODE": "explode"}, wantErr: true},
+		{env: map[string]string{"SNAKE_CORPSE_TICKS": "0"}, wantErr: true},
+		{env: map[string]string{"SNAKE_CORPSE_TICKS": "soon"}, wantErr: true},
+		{env: map[string]string{"SNAKE_PING_INTERVAL": "10"}, wantErr: true},
+		{env: map[string]string{"SNAKE_WRITE_TIMEOUT": "-1s"}, wantErr: true},
+		// the pong timeout has to leave room for at least one ping
+		{env: map[string]string{"SNAKE_PING_INTERVAL": "30s", "SNAKE_PONG_TIMEOUT": "30s"}, wantErr: true},
+		{env: map[string]string{"SNAKE_MAX_MESSAGE_SIZE": "0"}, wantErr: true},
+		// a grace of 0s ends the match as soon as everyone is gone
+		{
+			env:  map[string]string{"SNAKE_RECONNECT_GRACE": "0s"},
+			want: with(func(cfg *Config) { cfg.ReconnectGrace = 0 }),
+		},
+		{env: map[string]string{"SNAKE_RECONNECT_GRACE": "-1s"}, wantErr: true},
+		{
+			env:  map[string]string{"SNAKE_SPECTATOR_DELAY": "0s", "SNAKE_SPECTATOR_CHAT": "false"},
+			want: with(func(cfg *Config) { cfg.SpectatorDelay, cfg.SpectatorChat = 0, false }),
+		},
+		{env: map[string]string{"SNAKE_SPECTATOR_DELAY": "soon"}, wantErr: true},
+		{env: map[string]string{"SNAKE_SPECTATOR_CHAT": "maybe"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		for _, name := range []string{"SNAKE_DEATH_MODE", "SNAKE_CORPSE_TICKS", "SNAKE_PING_INTERVAL", "SNAKE_PONG_TIMEOUT", "SNAKE_WRITE_TIMEOUT", "SNAKE_MAX_MESSAGE_SIZE", "SNAKE_RECONNECT_GRACE", "SNAKE_SPECTATOR_DELAY", "SNAKE_SPECTATOR_CHAT"} {
+			t.Setenv(name, tt.env[name])
+		}
+		cfg, err := ConfigFromEnv()
+		if (err != nil) != tt.wantErr || (!tt.wantErr && cfg != tt.want) {
+			t.Errorf("env %v: got %+v, %v", tt.env, cfg, err)
+		}
+	}
+}
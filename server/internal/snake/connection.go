@@ -0,0 +1,254 @@
+package snake
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// SEND_QUEUE_SIZE is how many frames may wait for a slow client
+	SEND_QUEUE_SIZE = 64
+	// MAX_QUEUED_STATES is how many board states may wait, newer ones are dropped
+	MAX_QUEUED_STATES = 2
+)
+
+// Why a connection ended
+const (
+	DisconnectClosed        = "closed"
+	DisconnectTimeout       = "heartbeat_timeout"
+	DisconnectTooLarge      = "message_too_large"
+	DisconnectQueueOverflow = "send_queue_overflow"
+	DisconnectWriteFailed   = "write_failed"
+	DisconnectLost          = "connection_lost"
+)
+
+var (
+	ErrConnectionClosed = errors.New("connection closed")
+	ErrSendQueueFull    = errors.New("send queue full")
+)
+
+type frameKind int
+
+const (
+	// frameMessage must reach the client, like chat or game_over
+	frameMessage frameKind = iota
+	// frameState is a board state that can be replaced by a later snapshot
+	frameState
+	// frameClose ends the connection after the frames queued before it
+	frameClose
+)
+
+type frame struct {
+	kind frameKind
+	data []byte
+}
+
+// playerConn is a player's socket and the encoding it negotiated. Only its
+// write loop writes to the socket, everyone else queues frames and moves on
+// so a slow client can't hold up the match.
+type playerConn struct {
+	conn   *websocket.Conn
+	codec  wireCodec
+	config Config
+	// role is RolePlayer or RoleSpectator, set before the handshake
+	role   string
+	outbox chan frame
+	// queuedStates counts the state frames waiting in the outbox
+	queuedStates atomic.Int32
+	done         chan struct{}
+	closing      sync.Once
+	closed       sync.Once
+	// reason is the first recorded cause of the disconnect
+	reason   string
+	reasonMu sync.Mutex
+}
+
+func newPlayerConn(conn *websocket.Conn, codec wireCodec, cfg Config) *playerConn {
+	pc := &playerConn{
+		conn:   conn,
+		codec:  codec,
+		config: cfg,
+		outbox: make(chan frame, SEND_QUEUE_SIZE),
+		done:   make(chan struct{}),
+	}
+
+	conn.SetReadLimit(cfg.MaxMessageSize)
+	conn.SetPongHandler(func(string) error {
+		pc.keepAlive()
+		return nil
+	})
+	go pc.writeLoop()
+	return pc
+}
+
+// keepAlive pushes the read deadline back, any message or pong counts
+func (pc *playerConn) keepAlive() {
+	pc.conn.SetReadDeadline(time.Now().Add(pc.config.PongTimeout))
+}
+
+func (pc *playerConn) writeLoop() {
+	ping := time.NewTicker(pc.config.PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-pc.done:
+			return
+		case <-ping.C:
+			if err := pc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pc.config.WriteTimeout)); err != nil {
+				log.Printf("Ping to %s failed, closing connection: %v", pc.conn.RemoteAddr(), err)
+				pc.close(DisconnectWriteFailed)
+				return
+			}
+		case f := <-pc.outbox:
+			pc.conn.SetWriteDeadline(time.Now().Add(pc.config.WriteTimeout))
+			switch f.kind {
+			case frameClose:
+				pc.conn.WriteMessage(websocket.CloseMessage, f.data)
+				pc.close(DisconnectClosed)
+				return
+			case frameState:
+				pc.queuedStates.Add(-1)
+			}
+			if err := pc.conn.WriteMessage(pc.codec.FrameType(), f.data); err != nil {
+				log.Printf("Write to %s failed, closing connection: %v", pc.conn.RemoteAddr(), err)
+				pc.close(DisconnectWriteFailed)
+				return
+			}
+		}
+	}
+}
+
+// isClosed reports whether the connection has been closed, by either side or
+// for missing the heartbeat
+func (pc *playerConn) isClosed() bool {
+	select {
+	case <-pc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueue hands a frame to the write loop without ever blocking
+func (pc *playerConn) enqueue(f frame) error {
+	select {
+	case <-pc.done:
+		return ErrConnectionClosed
+	default:
+	}
+
+	select {
+	case pc.outbox <- f:
+		return nil
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// queue sends a message that must not be lost. A client too far behind to
+// take it is disconnected, it can reconnect and start over from a snapshot.
+func (pc *playerConn) queue(data []byte) error {
+	err := pc.enqueue(frame{kind: frameMessage, data: data})
+	if errors.Is(err, ErrSendQueueFull) {
+		log.Printf("Send queue of %s overflowed, disconnecting", pc.conn.RemoteAddr())
+		pc.close(DisconnectQueueOverflow)
+	}
+	return err
+}
+
+// queueState sends a board state unless the client is still behind on
+// earlier ones. It reports false when the state was dropped.
+func (pc *playerConn) queueState(data []byte) bool {
+	if pc.queuedStates.Load() >= MAX_QUEUED_STATES {
+		return false
+	}
+	pc.queuedStates.Add(1)
+	if err := pc.enqueue(frame{kind: frameState, data: data}); err != nil {
+		pc.queuedStates.Add(-1)
+		return false
+	}
+	return true
+}
+
+// send wraps the payload in an envelope, seq is the client message it answers
+func (pc *playerConn) send(msgType string, seq int64, payload any) error {
+	data, err := pc.codec.Marshal(Envelope[any]{
+		Type:    msgType,
+		Version: PROTOCOL_VERSION,
+		Seq:     seq,
+		Payload: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal %s: %v", msgType, err)
+	}
+	return pc.queue(data)
+}
+
+func (pc *playerConn) sendError(seq int64, code, message string) {
+	if err := pc.send(MessageError, seq, ErrorNotice{Code: code, Message: message}); err != nil {
+		log.Printf("Error sending %s error: %v", code, err)
+	}
+}
+
+// closeWith closes the connection once the frames already queued are sent
+func (pc *playerConn) closeWith(code int, reason string) {
+	pc.closing.Do(func() {
+		if reason != "" {
+			pc.recordReason(reason)
+		}
+		closeMsg := websocket.FormatCloseMessage(code, reason)
+		if err := pc.enqueue(frame{kind: frameClose, data: closeMsg}); err != nil {
+			pc.close(DisconnectClosed)
+		}
+	})
+}
+
+// close drops the connection right away, queued frames are lost
+func (pc *playerConn) close(reason string) {
+	pc.recordReason(reason)
+	pc.closed.Do(func() {
+		close(pc.done)
+		pc.conn.Close()
+	})
+}
+
+func (pc *playerConn) recordReason(reason string) {
+	pc.reasonMu.Lock()
+	defer pc.reasonMu.Unlock()
+
+	if pc.reason == "" {
+		pc.reason = reason
+	}
+}
+
+// disconnectReason tells why the connection ended, empty while it is open
+func (pc *playerConn) disconnectReason() string {
+	pc.reasonMu.Lock()
+	defer pc.reasonMu.Unlock()
+
+	return pc.reason
+}
+
+// readFailed closes the connection after a failed read and records why
+func (pc *playerConn) readFailed(err error) {
+	var netErr net.Error
+	switch {
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway):
+		pc.close(DisconnectClosed)
+	case errors.Is(err, websocket.ErrReadLimit):
+		// the websocket library already sent the client a 1009 close
+		pc.close(DisconnectTooLarge)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		pc.close(DisconnectTimeout)
+	default:
+		pc.close(DisconnectLost)
+	}
+}
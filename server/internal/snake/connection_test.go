@@ -0,0 +1,248 @@
+package snake
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// servePlayerConn connects a client to a server side playerConn. Without
+// start the write loop isn't running, so frames stay in the outbox like they
+// do for a client that stopped reading.
+func servePlayerConn(t *testing.T, subprotocol string, start bool) (*playerConn, *websocket.Conn) {
+	return servePlayerConnWith(t, subprotocol, testConfig, start)
+}
+
+func servePlayerConnWith(t *testing.T, subprotocol string, cfg Config, start bool) (*playerConn, *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *playerConn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if start {
+			conns <- newPlayerConn(conn, codecFor(conn.Subprotocol()), cfg)
+			return
+		}
+		conns <- &playerConn{
+			conn:   conn,
+			codec:  codecFor(conn.Subprotocol()),
+			config: cfg,
+			outbox: make(chan frame, SEND_QUEUE_SIZE),
+			done:   make(chan struct{}),
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{subprotocol}}
+	client, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc := <-conns
+	t.Cleanup(func() {
+		pc.close(DisconnectClosed)
+		client.Close()
+	})
+	return pc, client
+}
+
+func TestQueueStateDropsWhileBehind(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.StartGame("m-behind", "snake", []string{"a"})
+	ss.AddPlayer("m-behind", "a")
+	pc, client := servePlayerConn(t, SUBPROTOCOL_JSON, false)
+	registerConnection("m-behind", "a", pc)
+	t.Cleanup(func() { unregisterConnection("m-behind", "a", pc) })
+
+	for tick := int64(1); tick <= MAX_QUEUED_STATES+1; tick++ {
+		ss.broadcastBoardState("m-behind", tick)
+	}
+	if len(pc.outbox) != MAX_QUEUED_STATES || pc.queuedStates.Load() != MAX_QUEUED_STATES {
+		t.Fatalf("%d frames and %d states queued, want %d", len(pc.outbox), pc.queuedStates.Load(), MAX_QUEUED_STATES)
+	}
+	// messages that must arrive are still queued behind the states
+	if err := pc.send(MessageChat, 0, PlayerChat{From: "b", Message: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	go pc.writeLoop()
+	want := []struct {
+		msgType string
+		tick    int64
+	}{{MessageSnapshot, 1}, {MessageDelta, 2}, {MessageChat, 0}}
+	for _, w := range want {
+		msg := readTestMessage[StateUpdate](t, client)
+		if msg.Type != w.msgType || msg.Payload.Tick != w.tick {
+			t.Fatalf("got %s of tick %d, want %s of tick %d", msg.Type, msg.Payload.Tick, w.msgType, w.tick)
+		}
+	}
+
+	// the client missed tick 3, so it catches up from a snapshot
+	ss.broadcastBoardState("m-behind", 4)
+	if msg := readTestMessage[StateUpdate](t, client); msg.Type != MessageSnapshot || msg.Payload.Tick != 4 {
+		t.Fatalf("got %s of tick %d, want the snapshot of tick 4", msg.Type, msg.Payload.Tick)
+	}
+}
+
+func TestQueueOverflowDisconnects(t *testing.T) {
+	pc, client := servePlayerConn(t, SUBPROTOCOL_JSON, false)
+
+	for i := range SEND_QUEUE_SIZE {
+		if err := pc.queue([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	if err := pc.queue([]byte("one too many")); !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("overflowing message: got %v, want ErrSendQueueFull", err)
+	}
+	select {
+	case <-pc.done:
+	default:
+		t.Fatal("connection is still open after its queue overflowed")
+	}
+	if reason := pc.disconnectReason(); reason != DisconnectQueueOverflow {
+		t.Fatalf("disconnect reason = %q, want %q", reason, DisconnectQueueOverflow)
+	}
+
+	if err := pc.queue([]byte("after")); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("message after disconnecting: got %v, want ErrConnectionClosed", err)
+	}
+	if pc.queueState([]byte("state")) {
+		t.Fatal("state queued on a closed connection")
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatal("client can still read from a dropped connection")
+	}
+}
+
+func TestWriteLoopIsTheOnlyWriter(t *testing.T) {
+	pc, client := servePlayerConn(t, SUBPROTOCOL_JSON, true)
+
+	// gorilla/websocket panics on concurrent writes, and -race reports them
+	const senders, messages = 8, 6
+	var wg sync.WaitGroup
+	for s := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range messages {
+				if err := pc.send(MessageChat, 0, PlayerChat{From: fmt.Sprint(s), Message: fmt.Sprint(m)}); err != nil {
+					t.Errorf("sender %d message %d: %v", s, m, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	pc.closeWith(websocket.CloseNormalClosure, "game over")
+
+	// every message arrives in order per sender, then the close frame
+	next := make(map[string]int)
+	for range senders * messages {
+		msg := readTestMessage[PlayerChat](t, client)
+		if want := fmt.Sprint(next[msg.Payload.From]); msg.Payload.Message != want {
+			t.Fatalf("sender %s: got message %s, want %s", msg.Payload.From, msg.Payload.Message, want)
+		}
+		next[msg.Payload.From]++
+	}
+	if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("connection ended with %v, want a normal close after the messages", err)
+	}
+}
+
+// readUntilClosed reads like WsHandler does and returns why the connection ended
+func readUntilClosed(pc *playerConn) <-chan string {
+	reason := make(chan string, 1)
+	go func() {
+		pc.keepAlive()
+		for {
+			if _, _, err := pc.conn.ReadMessage(); err != nil {
+				pc.readFailed(err)
+				reason <- pc.disconnectReason()
+				return
+			}
+			pc.keepAlive()
+		}
+	}()
+	return reason
+}
+
+func TestHeartbeat(t *testing.T) {
+	cfg := testConfig
+	cfg.PingInterval, cfg.PongTimeout = 20*time.Millisecond, 100*time.Millisecond
+	cfg.MaxMessageSize = 64
+
+	tests := []struct {
+		name   string
+		client func(client *websocket.Conn)
+		want   string
+	}{
+		{
+			// only reading clients answer pings
+			name:   "silent client",
+			client: func(client *websocket.Conn) {},
+			want:   DisconnectTimeout,
+		},
+		{
+			name: "client closes",
+			client: func(client *websocket.Conn) {
+				client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			},
+			want: DisconnectClosed,
+		},
+		{
+			name: "oversized message",
+			client: func(client *websocket.Conn) {
+				client.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 65)))
+			},
+			want: DisconnectTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, client := servePlayerConnWith(t, SUBPROTOCOL_JSON, cfg, true)
+			reason := readUntilClosed(pc)
+			tt.client(client)
+
+			select {
+			case got := <-reason:
+				if got != tt.want {
+					t.Fatalf("disconnect reason = %q, want %q", got, tt.want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("connection is still open")
+			}
+		})
+	}
+}
+
+func TestHeartbeatKeepsAnsweringClientAlive(t *testing.T) {
+	cfg := testConfig
+	cfg.PingInterval, cfg.PongTimeout = 20*time.Millisecond, 100*time.Millisecond
+	pc, client := servePlayerConnWith(t, SUBPROTOCOL_JSON, cfg, true)
+	reason := readUntilClosed(pc)
+
+	// the default ping handler answers with a pong while the client reads
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case got := <-reason:
+		t.Fatalf("connection closed with %q although the client answered every ping", got)
+	case <-time.After(5 * cfg.PongTimeout):
+	}
+}
@@ -1,9 +1,28 @@
 package snake
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
+
+// INPUT_BUFFER_SIZE is how many moves a player can queue ahead of the ticks
+const INPUT_BUFFER_SIZE = 3
+
+type queuedInput struct {
+	direction Direction
+	seq       int64
+}
 
 type SnakeController struct {
 	Snake *Snake
+	// inputs wait for the movement ticks, oldest first
+	inputs []queuedInput
+	// lastSeq is the highest sequence number received, ackSeq the highest
+	// one that was applied, rejected or dropped. Dropped inputs are only
+	// acked once the inputs queued before them are done.
+	lastSeq    int64
+	ackSeq     int64
+	droppedSeq int64
 	mu    sync.Mutex
 }
 
@@ -18,18 +37,66 @@ func NewSnakeController(snake *Snake) *SnakeController {
 	}
 }
 
-func (sc *SnakeController) RunSnake(snakeBoard *SnakeBoard) (bool, string) {
+// KeyboardController queues a direction change for the coming movement ticks.
+// Sequence numbers are optional, when given stale or repeated ones are ignored.
+func (sc *SnakeController) KeyboardController(option Direction, seq int64) error {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	isCol, msg := sc.Snake.Movement(snakeBoard)
-	return isCol, msg
+	if seq > 0 {
+		if seq <= sc.lastSeq {
+			return fmt.Errorf("stale input %d, already received %d", seq, sc.lastSeq)
+		}
+		sc.lastSeq = seq
+	}
+	if len(sc.inputs) >= INPUT_BUFFER_SIZE {
+		sc.droppedSeq = max(sc.droppedSeq, seq)
+		return fmt.Errorf("input buffer full, dropped input %d", seq)
+	}
+
+	sc.inputs = append(sc.inputs, queuedInput{direction: option, seq: seq})
+	return nil
 }
 
-func (sc *SnakeController) KeyboardController(option Direction) error {
+// ApplyInput turns the snake with the oldest queued input that is legal for
+// its current direction. Illegal inputs, like reversing, are discarded. It
+// reports the direction taken, if any.
+func (sc *SnakeController) ApplyInput() (Direction, bool) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	sc.Snake.Controller(option)
-	return nil
+	defer func() {
+		if len(sc.inputs) == 0 {
+			sc.ackSeq = max(sc.ackSeq, sc.droppedSeq)
+		}
+	}()
+
+	for len(sc.inputs) > 0 {
+		input := sc.inputs[0]
+		sc.inputs = sc.inputs[1:]
+		sc.ackSeq = max(sc.ackSeq, input.seq)
+		if sc.Snake.Controller(input.direction).Ok {
+			return input.direction, true
+		}
+	}
+	return "", false
+}
+
+// ResetInputs forgets queued moves and sequence numbers of an old connection
+func (sc *SnakeController) ResetInputs() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.inputs = nil
+	sc.lastSeq = 0
+	sc.ackSeq = 0
+	sc.droppedSeq = 0
+}
+
+// InputAck is the highest input sequence number the server is done with
+func (sc *SnakeController) InputAck() int64 {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.ackSeq
 }
\ No newline at end of file
@@ -0,0 +1,116 @@
+package snake
+
+import "testing"
+
+func TestApplyInput(t *testing.T) {
+	type input struct {
+		dir     Direction
+		seq     int64
+		wantErr bool
+	}
+	// tick is the state after one ApplyInput
+	type tick struct {
+		dir Direction
+		ack int64
+	}
+	tests := []struct {
+		name        string
+		heading     Direction
+		inputs      []input
+		ticks       []tick
+		wantDropped int64
+	}{
+		{
+			name:    "up then left within one move",
+			heading: RIGHT,
+			inputs:  []input{{dir: UP, seq: 1}, {dir: LEFT, seq: 2}},
+			ticks:   []tick{{UP, 1}, {LEFT, 2}, {LEFT, 2}},
+		},
+		{
+			name:    "reversal is discarded for the next input",
+			heading: RIGHT,
+			inputs:  []input{{dir: LEFT, seq: 1}, {dir: DOWN, seq: 2}},
+			ticks:   []tick{{DOWN, 2}},
+		},
+		{
+			name:    "only a reversal",
+			heading: RIGHT,
+			inputs:  []input{{dir: LEFT, seq: 1}},
+			ticks:   []tick{{RIGHT, 1}},
+		},
+		{
+			name:    "same direction is discarded",
+			heading: UP,
+			inputs:  []input{{dir: UP, seq: 1}, {dir: UP, seq: 2}, {dir: RIGHT, seq: 3}},
+			ticks:   []tick{{RIGHT, 3}},
+		},
+		{
+			name:    "full buffer drops input",
+			heading: RIGHT,
+			inputs: []input{
+				{dir: UP, seq: 1},
+				{dir: LEFT, seq: 2},
+				{dir: DOWN, seq: 3},
+				{dir: RIGHT, seq: 4, wantErr: true},
+				{dir: UP, seq: 5, wantErr: true},
+			},
+			// dropped inputs are acked once the queue ahead of them is done
+			ticks:       []tick{{UP, 1}, {LEFT, 2}, {DOWN, 5}},
+			wantDropped: 5,
+		},
+		{
+			name:    "stale input is ignored",
+			heading: RIGHT,
+			inputs:  []input{{dir: UP, seq: 2}, {dir: DOWN, seq: 2, wantErr: true}, {dir: DOWN, seq: 1, wantErr: true}},
+			ticks:   []tick{{UP, 2}, {UP, 2}},
+		},
+		{
+			name:    "inputs without sequence numbers",
+			heading: RIGHT,
+			inputs:  []input{{dir: UP}, {dir: LEFT}},
+			ticks:   []tick{{UP, 0}, {LEFT, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSnake(SnakeIdentity{PlayerId: "a"}, Point{5, 5})
+			s.Direction = tt.heading
+			sc := NewSnakeController(s)
+
+			for _, in := range tt.inputs {
+				if err := sc.KeyboardController(in.dir, in.seq); (err != nil) != in.wantErr {
+					t.Fatalf("input %v %d: error = %v, want error %v", in.dir, in.seq, err, in.wantErr)
+				}
+			}
+			for i, want := range tt.ticks {
+				sc.ApplyInput()
+				if s.Direction != want.dir || sc.InputAck() != want.ack {
+					t.Fatalf("tick %d: heading %v acked %d, want %v acked %d", i+1, s.Direction, sc.InputAck(), want.dir, want.ack)
+				}
+			}
+			if sc.droppedSeq != tt.wantDropped {
+				t.Errorf("droppedSeq = %d, want %d", sc.droppedSeq, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestResetInputs(t *testing.T) {
+	sc := NewSnakeController(NewSnake(SnakeIdentity{PlayerId: "a"}, Point{5, 5}))
+	for seq := int64(1); seq <= INPUT_BUFFER_SIZE+1; seq++ {
+		sc.KeyboardController(UP, seq)
+	}
+	sc.ResetInputs()
+	if direction, ok := sc.ApplyInput(); ok {
+		t.Fatalf("took a %v turn queued before the reset", direction)
+	}
+
+	// a new connection starts counting again
+	if sc.InputAck() != 0 || sc.Snake.Direction != RIGHT {
+		t.Fatalf("after reset: heading %v acked %d", sc.Snake.Direction, sc.InputAck())
+	}
+	if err := sc.KeyboardController(UP, 1); err != nil {
+		t.Fatalf("first input after reset: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package snake
+
+import "slices"
+
+// SnakeDelta is what changed about one snake since the previous tick
+type SnakeDelta struct {
+	PlayerId string `json:"playerId"`
+	// Head is the new head, the old head becomes the newest body segment
+	Head *Point `json:"head,omitempty"`
+	// TailRemoved is how many segments left the tail end of the body
+	TailRemoved int       `json:"tailRemoved,omitempty"`
+	Direction   Direction `json:"direction,omitempty"`
+	Score       *int      `json:"score,omitempty"`
+	Died        bool      `json:"died,omitempty"`
+	DeathReason string    `json:"deathReason,omitempty"`
+}
+
+// BoardDelta turns the board of the previous tick into the current one.
+// Obstacles never change and are only sent in snapshots.
+type BoardDelta struct {
+	// Full snakes are new on the board or changed in a way a SnakeDelta
+	// cannot express, clients replace their copy
+	Full         []Snake      `json:"full,omitempty"`
+	Snakes       []SnakeDelta `json:"snakes,omitempty"`
+	Removed      []string     `json:"removed,omitempty"`
+	FoodsAdded   []Food       `json:"foodsAdded,omitempty"`
+	FoodsRemoved []Food       `json:"foodsRemoved,omitempty"`
+}
+
+// boardFrame is the board as last sent to clients
+type boardFrame struct {
+	snakes map[string]Snake
+	foods  []Food
+}
+
+// Delta diffs the board against the frame of the previous call
+func (sb *SnakeBoard) Delta() *BoardDelta {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	current := sb.frame()
+	previous := sb.lastFrame
+	sb.lastFrame = current
+	if previous == nil {
+		previous = &boardFrame{snakes: map[string]Snake{}}
+	}
+
+	delta := &BoardDelta{}
+	for _, playerId := range sortedKeys(current.snakes) {
+		snake := current.snakes[playerId]
+		before, existed := previous.snakes[playerId]
+		switch {
+		case !existed:
+			delta.Full = append(delta.Full, snake)
+		case snake.Removed && !before.Removed:
+			delta.Removed = append(delta.Removed, playerId)
+		case !snake.Removed:
+			if d, ok := diffSnake(before, snake); !ok {
+				delta.Full = append(delta.Full, snake)
+			} else if d != nil {
+				delta.Snakes = append(delta.Snakes, *d)
+			}
+		}
+	}
+
+	delta.FoodsAdded = subtractFoods(current.foods, previous.foods)
+	delta.FoodsRemoved = subtractFoods(previous.foods, current.foods)
+	return delta
+}
+
+func (sb *SnakeBoard) frame() *boardFrame {
+	frame := &boardFrame{
+		snakes: make(map[string]Snake, len(sb.SnakeControllers)),
+		foods:  slices.Clone(sb.Foods),
+	}
+	for playerId, sc := range sb.SnakeControllers {
+		snake := *sc.Snake
+		snake.SnakeBody = slices.Clone(snake.SnakeBody)
+		frame.snakes[playerId] = snake
+	}
+	return frame
+}
+
+// diffSnake returns nil when nothing changed and false when the change is
+// not a move of at most one cell
+func diffSnake(before, after Snake) (*SnakeDelta, bool) {
+	d := &SnakeDelta{PlayerId: after.PlayerId}
+	changed := false
+
+	if after.SnakeHead != before.SnakeHead {
+		// after a move the body is the old body minus some tail plus the old head
+		moved := append(slices.Clone(before.SnakeBody), before.SnakeHead)
+		removed := len(moved) - len(after.SnakeBody)
+		if removed < 0 || !slices.Equal(moved[removed:], after.SnakeBody) {
+			return nil, false
+		}
+		head := after.SnakeHead
+		d.Head, d.TailRemoved, changed = &head, removed, true
+	} else if !slices.Equal(before.SnakeBody, after.SnakeBody) {
+		return nil, false
+	}
+
+	if after.Direction != before.Direction {
+		d.Direction, changed = after.Direction, true
+	}
+	if after.Score.Value != before.Score.Value {
+		score := after.Score.Value
+		d.Score, changed = &score, true
+	}
+	if before.IsAlive && !after.IsAlive {
+		d.Died, d.DeathReason, changed = true, after.DeathReason, true
+	} else if !before.IsAlive && after.IsAlive {
+		return nil, false
+	}
+
+	if !changed {
+		return nil, true
+	}
+	return d, true
+}
+
+// subtractFoods returns the foods of a that are not in b, counting duplicates
+func subtractFoods(a, b []Food) []Food {
+	remaining := make(map[Food]int, len(b))
+	for _, food := range b {
+		remaining[food]++
+	}
+	var diff []Food
+	for _, food := range a {
+		if remaining[food] > 0 {
+			remaining[food]--
+			continue
+		}
+		diff = append(diff, food)
+	}
+	return diff
+}
+
+func sortedKeys(snakes map[string]Snake) []string {
+	keys := make([]string, 0, len(snakes))
+	for key := range snakes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,180 @@
+package snake
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+const deltaTestTicks = 300
+
+// clientBoard is the board as a client rebuilds it from a snapshot and the
+// deltas after it
+type clientBoard struct {
+	snakes map[string]Snake
+	foods  []Food
+}
+
+func newClientBoard(state *SnakeBoardPlayerInformation) *clientBoard {
+	cb := &clientBoard{snakes: make(map[string]Snake), foods: slices.Clone(state.Foods)}
+	snakes := state.OtherSnakes
+	if !state.PlayerSnake.Removed {
+		snakes = append(snakes, state.PlayerSnake)
+	}
+	for _, s := range snakes {
+		s.SnakeBody = slices.Clone(s.SnakeBody)
+		cb.snakes[s.PlayerId] = s
+	}
+	return cb
+}
+
+func (cb *clientBoard) apply(d *BoardDelta) {
+	for _, s := range d.Full {
+		s.SnakeBody = slices.Clone(s.SnakeBody)
+		cb.snakes[s.PlayerId] = s
+	}
+	for _, sd := range d.Snakes {
+		s := cb.snakes[sd.PlayerId]
+		if sd.Head != nil {
+			s.SnakeBody = append(slices.Clone(s.SnakeBody), s.SnakeHead)[sd.TailRemoved:]
+			s.SnakeHead = *sd.Head
+		}
+		if sd.Direction != "" {
+			s.Direction = sd.Direction
+		}
+		if sd.Score != nil {
+			s.Score.Value = *sd.Score
+		}
+		if sd.Died {
+			s.IsAlive, s.DeathReason = false, sd.DeathReason
+		}
+		cb.snakes[sd.PlayerId] = s
+	}
+	for _, playerId := range d.Removed {
+		delete(cb.snakes, playerId)
+	}
+	cb.foods = append(subtractFoods(cb.foods, d.FoodsRemoved), d.FoodsAdded...)
+}
+
+// diff describes the first difference to the board a snapshot shows
+func (cb *clientBoard) diff(state *SnakeBoardPlayerInformation) string {
+	want := newClientBoard(state)
+	if !slices.Equal(slices.Sorted(maps.Keys(cb.snakes)), slices.Sorted(maps.Keys(want.snakes))) {
+		return fmt.Sprintf("snakes %v, want %v", slices.Sorted(maps.Keys(cb.snakes)), slices.Sorted(maps.Keys(want.snakes)))
+	}
+	for playerId, w := range want.snakes {
+		got := cb.snakes[playerId]
+		if got.SnakeHead != w.SnakeHead || !slices.Equal(got.SnakeBody, w.SnakeBody) || got.Direction != w.Direction ||
+			got.Score != w.Score || got.IsAlive != w.IsAlive || got.DeathReason != w.DeathReason {
+			return fmt.Sprintf("snake %s is %+v, want %+v", playerId, got, w)
+		}
+	}
+	if len(subtractFoods(cb.foods, want.foods)) > 0 || len(subtractFoods(want.foods, cb.foods)) > 0 {
+		return fmt.Sprintf("foods %v, want %v", cb.foods, want.foods)
+	}
+	return ""
+}
+
+// playDeltaTestMatch runs a four player match with random turns, moving every
+// tick. onTick gets the opening snapshot with a nil delta, then the delta and
+// the board seen by player a after every tick.
+func playDeltaTestMatch(deathMode string, onTick func(tick int64, delta *BoardDelta, state *SnakeBoardPlayerInformation)) {
+	players := []string{"a", "b", "c", "d"}
+	sb := NewSnakeBoard(Config{DeathMode: deathMode, CorpseTicks: 5}, RulesFor("four-snake-game"), 7, players)
+	for _, playerId := range players {
+		sb.AddPlayer(SnakeIdentity{PlayerId: playerId})
+	}
+	onTick(0, nil, sb.GetSnakeBoard("a"))
+	sb.Delta()
+
+	turns := rand.New(rand.NewPCG(7, 7))
+	directions := []Direction{UP, DOWN, LEFT, RIGHT}
+	for range deltaTestTicks {
+		for _, playerId := range players {
+			if turns.IntN(4) == 0 {
+				// illegal turns are refused, which is fine here
+				sb.ExecutePlayerMovement(playerId, directions[turns.IntN(len(directions))], 0)
+			}
+		}
+		// the steps of a match, without waiting for the move ticks
+		sb.Tick++
+		sb.applyInputs()
+		sb.moveSnakes(players)
+		sb.decayCorpses()
+		if sb.Tick%FOOD_INTERVAL_TICKS == 0 {
+			sb.generateFood()
+		}
+		onTick(sb.Tick, sb.Delta(), sb.GetSnakeBoard("a"))
+	}
+}
+
+func TestDeltasRebuildTheBoard(t *testing.T) {
+	for _, deathMode := range []string{DeathModeCorpse, DeathModeRemove, DeathModeFood} {
+		t.Run(deathMode, func(t *testing.T) {
+			var client *clientBoard
+			var deaths int
+			playDeltaTestMatch(deathMode, func(tick int64, delta *BoardDelta, state *SnakeBoardPlayerInformation) {
+				if delta == nil {
+					client = newClientBoard(state)
+					return
+				}
+				// snakes that leave no corpse are removed right away
+				deaths += len(delta.Removed)
+				for _, sd := range delta.Snakes {
+					if sd.Died {
+						deaths++
+					}
+				}
+				client.apply(delta)
+				if diff := client.diff(state); diff != "" {
+					t.Fatalf("tick %d: %s", tick, diff)
+				}
+			})
+			if deaths == 0 {
+				t.Fatal("no snake died, the deaths aren't covered")
+			}
+		})
+	}
+}
+
+func TestDeltasUseLessBandwidth(t *testing.T) {
+	// MessagePack snapshots are denser, so deltas save less of them
+	tests := []struct {
+		name     string
+		maxShare int
+	}{
+		{SUBPROTOCOL_JSON, 5},
+		{SUBPROTOCOL_MSGPACK, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec := codecFor(tt.name)
+			encodedSize := func(msgType string, payload any) int {
+				data, err := codec.Marshal(Envelope[any]{Type: msgType, Version: PROTOCOL_VERSION, Payload: payload})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(data)
+			}
+
+			var snapshotBytes, deltaBytes int
+			// both streams open with the same snapshot
+			playDeltaTestMatch(DeathModeCorpse, func(tick int64, delta *BoardDelta, state *SnakeBoardPlayerInformation) {
+				snapshot := encodedSize(MessageSnapshot, StateUpdate{Tick: tick, State: state})
+				snapshotBytes += snapshot
+				if delta == nil {
+					deltaBytes += snapshot
+					return
+				}
+				deltaBytes += encodedSize(MessageDelta, DeltaUpdate{Tick: tick, BoardDelta: delta})
+			})
+
+			t.Logf("%d ticks: snapshots %d bytes, deltas %d bytes", deltaTestTicks, snapshotBytes, deltaBytes)
+			if deltaBytes*tt.maxShare > snapshotBytes {
+				t.Fatalf("deltas take %d bytes, want under 1/%d of the %d bytes of snapshots", deltaBytes, tt.maxShare, snapshotBytes)
+			}
+		})
+	}
+}
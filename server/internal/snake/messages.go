@@ -0,0 +1,178 @@
+package snake
+
+// PROTOCOL_VERSION is bumped whenever a message changes incompatibly
+const PROTOCOL_VERSION = 1
+
+// Message types of the snake protocol
+const (
+	// client to server
+	MessageHello  = "hello"
+	MessageMove   = "move"
+	MessageChat   = "chat"
+	MessageResync = "resync"
+	// replays only
+	MessageReplaySpeed = "replay_speed"
+	MessageReplaySeek  = "replay_seek"
+
+	// server to client
+	MessageWelcome     = "welcome"
+	MessageSnapshot    = "snapshot"
+	MessageDelta       = "delta"
+	MessagePlayerDied  = "player_died"
+	MessageGameOver    = "game_over"
+	MessageAchievement = "achievement"
+	MessageError       = "error"
+	MessageReplayInfo  = "replay_info"
+)
+
+// Error codes sent back to clients
+const (
+	ErrorInvalidMessage     = "invalid_message"
+	ErrorUnsupportedVersion = "unsupported_version"
+	ErrorHandshakeRequired  = "handshake_required"
+	ErrorUnknownType        = "unknown_type"
+	ErrorInvalidPayload     = "invalid_payload"
+	ErrorMoveRejected       = "move_rejected"
+	ErrorInvalidResumeToken = "invalid_resume_token"
+	ErrorForbidden          = "forbidden"
+)
+
+// Envelope wraps every message in both directions. Seq numbers the client's
+// messages, the server echoes it on the messages that answer one.
+type Envelope[T any] struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	Seq     int64  `json:"seq,omitempty"`
+	Payload T      `json:"payload,omitempty"`
+}
+
+// noPayload is decoded when only the envelope of a message is needed
+type noPayload struct{}
+
+// messageSpec pairs a message type with its payload, nil when it has none
+type messageSpec struct {
+	Type    string
+	Payload any
+}
+
+// Messages of the protocol, they also drive the JSON Schema
+var (
+	clientMessages = []messageSpec{
+		{MessageHello, Hello{}},
+		{MessageMove, PlayerMove{}},
+		{MessageChat, PlayerChat{}},
+		{MessageResync, nil},
+		{MessageReplaySpeed, ReplaySpeed{}},
+		{MessageReplaySeek, ReplaySeek{}},
+	}
+	serverMessages = []messageSpec{
+		{MessageWelcome, Welcome{}},
+		{MessageSnapshot, StateUpdate{}},
+		{MessageDelta, DeltaUpdate{}},
+		{MessageChat, PlayerChat{}},
+		{MessagePlayerDied, PlayerDiedNotice{}},
+		{MessageGameOver, GameOverNotice{}},
+		{MessageAchievement, AchievementNotice{}},
+		{MessageError, ErrorNotice{}},
+		{MessageReplayInfo, ReplayInfo{}},
+	}
+)
+
+// Hello opens a connection, a player coming back to a match presents the
+// resume token of its earlier welcome
+type Hello struct {
+	ResumeToken string `json:"resumeToken,omitempty"`
+}
+
+type PlayerMove struct {
+	Direction Direction `json:"direction"`
+}
+
+type PlayerChat struct {
+	// From is filled in by the server
+	From    string `json:"from,omitempty"`
+	Message string `json:"message"`
+}
+
+// Welcome answers a hello with the settings of the connection
+type Welcome struct {
+	ProtocolVersion int    `json:"protocolVersion"`
+	Encoding        string `json:"encoding"`
+	PlayerId        string `json:"playerId"`
+	MatchId         string `json:"matchId"`
+	// Role is player or spectator
+	Role string `json:"role"`
+	// ResumeToken lets the player reconnect, Resumed is set when it did.
+	// Spectators don't get one.
+	ResumeToken string `json:"resumeToken,omitempty"`
+	Resumed     bool   `json:"resumed"`
+}
+
+type StateUpdate struct {
+	Tick int64 `json:"tick"`
+	// Ack is the seq of the receiving player's last move the server has handled
+	Ack int64 `json:"ack"`
+	// Spectators is how many people are watching the match
+	Spectators int                          `json:"spectators"`
+	State      *SnakeBoardPlayerInformation `json:"state"`
+}
+
+type DeltaUpdate struct {
+	Tick int64 `json:"tick"`
+	Ack  int64 `json:"ack"`
+	// Spectators is set when the number of spectators changed
+	Spectators *int `json:"spectators,omitempty"`
+	*BoardDelta
+}
+
+type PlayerDiedNotice struct {
+	Tick     int64  `json:"tick"`
+	PlayerId string `json:"playerId"`
+	Cause    string `json:"cause"`
+	Killer   string `json:"killer,omitempty"`
+	Score    int    `json:"score"`
+}
+
+type Standing struct {
+	PlayerId    string `json:"playerId"`
+	Placement   *int   `json:"placement,omitempty"`
+	Score       int    `json:"score"`
+	DeathReason string `json:"deathReason,omitempty"`
+}
+
+type GameOverNotice struct {
+	Tick      int64      `json:"tick"`
+	Reason    string     `json:"reason"`
+	Standings []Standing `json:"standings"`
+}
+
+type AchievementNotice struct {
+	PlayerId      string `json:"playerId"`
+	AchievementId string `json:"achievementId"`
+}
+
+type ErrorNotice struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ReplayInfo opens a replay after the welcome
+type ReplayInfo struct {
+	MatchId string   `json:"matchId"`
+	GameId  string   `json:"gameId"`
+	Players []string `json:"players"`
+	// Ticks is the last tick of the match
+	Ticks          int64 `json:"ticks"`
+	TickIntervalMs int64 `json:"tickIntervalMs"`
+	Speed          int   `json:"speed"`
+}
+
+// ReplaySpeed sets how many ticks a replay plays per tick of a live match
+type ReplaySpeed struct {
+	Speed int `json:"speed"`
+}
+
+// ReplaySeek jumps to a tick of the replay, it is answered with a snapshot
+type ReplaySeek struct {
+	Tick int64 `json:"tick"`
+}
@@ -0,0 +1,233 @@
+package snake
+
+import "game-server/internal/events"
+
+const (
+	DeathOutOfRange    = "Out of range"
+	DeathHitObstacle   = "Hit Obstacle"
+	DeathHeadOn        = "Head-on collision"
+	DeathHitSnakeHead  = "Hit other snake head"
+	DeathHitSnakeBody  = "Hit other snake body"
+	DeathSelfCollision = "Self Collision"
+)
+
+// plannedMove is where a living snake will be after this tick if it survives
+type plannedMove struct {
+	playerId string
+	snake    *Snake
+	next     Point
+	foodIdx  int
+	dead     bool
+	reason   string
+	killer   string
+}
+
+type occupant struct {
+	playerId string
+	isHead   bool
+	// corpse cells belong to a snake that died in an earlier tick
+	corpse bool
+}
+
+// moveSnakes advances every living snake one cell at the same time.
+//
+// All next heads are computed from the current board before anything moves:
+//   - heads entering the same cell, or swapping cells, kill both snakes
+//   - a head entering a cell that is part of a snake after the move kills the
+//     moving snake and credits the owner, unless the owner is already dead.
+//     Tails vacate their cell unless the snake grows this tick, so following
+//     a tail is safe.
+//   - contested food is never split: heads that meet on it die head-on and
+//     the food stays on the board
+//
+// Snakes that die keep their old position, which can block others, so
+// resolution repeats until no new snake dies.
+func (sb *SnakeBoard) moveSnakes(playerIds []string) {
+	moves := make([]*plannedMove, 0, len(playerIds))
+	for _, playerId := range playerIds {
+		sc, ok := sb.SnakeControllers[playerId]
+		if !ok || !sc.Snake.IsAlive {
+			continue
+		}
+		next := executeDirMovement(sc.Snake.SnakeHead, sc.Snake.Direction)
+		_, _, foodIdx := checkFood(sb.Foods, next)
+		move := &plannedMove{playerId: playerId, snake: sc.Snake, next: next, foodIdx: foodIdx}
+		if reason := sb.staticCollision(next); reason != "" {
+			move.dead, move.reason = true, reason
+		}
+		moves = append(moves, move)
+	}
+
+	for sb.resolveCollisions(moves) {
+	}
+
+	eaten := make([]int, 0)
+	for _, m := range moves {
+		if m.dead {
+			m.snake.die(m.reason, m.killer, sb.Tick)
+			sb.pendingEvents = append(sb.pendingEvents, events.Event{
+				Type:     events.PlayerDied,
+				Tick:     sb.Tick,
+				PlayerId: m.playerId,
+				Value:    m.snake.Score.Value,
+				Cause:    m.reason,
+				Killer:   m.killer,
+			})
+			continue
+		}
+		if m.foodIdx < 0 {
+			executeMovement(m.next, m.snake, false)
+			continue
+		}
+		m.snake.eat(m.next, sb.Foods[m.foodIdx])
+		eaten = append(eaten, m.foodIdx)
+		sb.pendingEvents = append(sb.pendingEvents, events.Event{
+			Type:     events.ScoreChanged,
+			Tick:     sb.Tick,
+			PlayerId: m.playerId,
+			Value:    m.snake.Score.Value,
+		})
+	}
+	sb.removeFoods(eaten)
+
+	// Bodies are cleared last so food dropped by a corpse is never eaten in
+	// the tick the snake died
+	for _, m := range moves {
+		if m.dead {
+			sb.clearDeadSnake(m.snake)
+		}
+	}
+}
+
+// clearDeadSnake applies the configured death mode to a snake that just died
+func (sb *SnakeBoard) clearDeadSnake(snake *Snake) {
+	switch sb.config.DeathMode {
+	case DeathModeRemove:
+		snake.remove()
+	case DeathModeFood:
+		for _, cell := range snake.cells() {
+			if _, _, idx := checkFood(sb.Foods, cell); idx < 0 {
+				sb.Foods = append(sb.Foods, Food{Position: cell, Value: 1})
+			}
+		}
+		snake.remove()
+	default:
+		snake.CorpseTicks = sb.config.CorpseTicks
+	}
+}
+
+// staticCollision checks the walls and obstacles, which never move
+func (sb *SnakeBoard) staticCollision(p Point) string {
+	if (p.X < 0 || p.X >= sb.Width) || (p.Y < 0 || p.Y >= sb.Height) {
+		return DeathOutOfRange
+	}
+	for _, obs := range sb.Obstacles {
+		for _, o := range obs.Object {
+			if p.X == o.X && p.Y == o.Y {
+				return DeathHitObstacle
+			}
+		}
+	}
+	return ""
+}
+
+// resolveCollisions marks the snakes that die against the current plan and
+// reports whether any new snake died
+func (sb *SnakeBoard) resolveCollisions(moves []*plannedMove) bool {
+	moving := make(map[string]*plannedMove, len(moves))
+	heads := make(map[Point][]*plannedMove)
+	for _, m := range moves {
+		if !m.dead {
+			moving[m.playerId] = m
+			heads[m.next] = append(heads[m.next], m)
+		}
+	}
+	occupied := sb.occupiedAfter(moving)
+
+	died := make([]*plannedMove, 0)
+	for _, m := range moving {
+		if len(heads[m.next]) > 1 || sb.swapsWith(m, heads) {
+			died = append(died, m)
+			m.reason = DeathHeadOn
+			continue
+		}
+		occ, ok := occupied[m.next]
+		switch {
+		case !ok:
+			continue
+		case occ.playerId == m.playerId:
+			m.reason = DeathSelfCollision
+		case occ.isHead:
+			m.reason = DeathHitSnakeHead
+		default:
+			m.reason = DeathHitSnakeBody
+			if !occ.corpse {
+				m.killer = occ.playerId
+			}
+		}
+		died = append(died, m)
+	}
+
+	// Mark after the scan so every snake is judged against the same plan
+	for _, m := range died {
+		m.dead = true
+	}
+	return len(died) > 0
+}
+
+// swapsWith reports a head moving onto the head of a snake moving the other way
+func (sb *SnakeBoard) swapsWith(m *plannedMove, heads map[Point][]*plannedMove) bool {
+	for _, other := range heads[m.snake.SnakeHead] {
+		if other != m && other.next == m.snake.SnakeHead && m.next == other.snake.SnakeHead {
+			return true
+		}
+	}
+	return false
+}
+
+// occupiedAfter maps every snake cell once the moving snakes have moved, not
+// counting their new heads. Snakes that are not moving keep their head, a
+// moving snake keeps its old head as its neck unless it had no body and
+// doesn't grow.
+func (sb *SnakeBoard) occupiedAfter(moving map[string]*plannedMove) map[Point]occupant {
+	occupied := make(map[Point]occupant)
+	for playerId, sc := range sb.SnakeControllers {
+		if sc.Snake.Removed {
+			continue
+		}
+		body := sc.Snake.SnakeBody
+		m, isMoving := moving[playerId]
+		if isMoving && m.foodIdx < 0 {
+			if len(body) == 0 {
+				// a snake of length 1 leaves its only cell
+				continue
+			}
+			// the tail moves away
+			body = body[1:]
+		}
+		corpse := !sc.Snake.IsAlive
+		for _, part := range body {
+			occupied[part] = occupant{playerId: playerId, corpse: corpse}
+		}
+		// a moving snake's old head becomes its neck
+		occupied[sc.Snake.SnakeHead] = occupant{playerId: playerId, isHead: !isMoving, corpse: corpse}
+	}
+	return occupied
+}
+
+func (sb *SnakeBoard) removeFoods(indexes []int) {
+	if len(indexes) == 0 {
+		return
+	}
+	eaten := make(map[int]bool, len(indexes))
+	for _, idx := range indexes {
+		eaten[idx] = true
+	}
+	foods := make([]Food, 0, len(sb.Foods)-len(eaten))
+	for idx, food := range sb.Foods {
+		if !eaten[idx] {
+			foods = append(foods, food)
+		}
+	}
+	sb.Foods = foods
+}
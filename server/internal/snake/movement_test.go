@@ -0,0 +1,321 @@
+package snake
+
+import (
+	"slices"
+	"testing"
+)
+
+// testSnake is a snake on a test board, body listed tail first
+type testSnake struct {
+	id   string
+	head Point
+	body []Point
+	dir  Direction
+	dead bool
+}
+
+type wantSnake struct {
+	alive  bool
+	reason string
+	killer string
+	// head and length are only checked for snakes that survive
+	head   Point
+	length int
+}
+
+func newTestBoard(snakes []testSnake, foods []Point) (*SnakeBoard, []string) {
+	sb := &SnakeBoard{
+		SnakeControllers: make(map[string]*SnakeController),
+		Width:            10,
+		Height:           10,
+		config:           Config{DeathMode: DeathModeCorpse, CorpseTicks: 10},
+	}
+	playerIds := make([]string, 0, len(snakes))
+	for _, ts := range snakes {
+		s := NewSnake(SnakeIdentity{PlayerId: ts.id}, ts.head)
+		s.SnakeBody = slices.Clone(ts.body)
+		s.Direction = ts.dir
+		s.IsAlive = !ts.dead
+		sb.SnakeControllers[ts.id] = NewSnakeController(s)
+		playerIds = append(playerIds, ts.id)
+	}
+	for _, p := range foods {
+		sb.Foods = append(sb.Foods, Food{Position: p, Value: 1})
+	}
+	return sb, playerIds
+}
+
+func TestMoveSnakes(t *testing.T) {
+	tests := []struct {
+		name   string
+		snakes []testSnake
+		foods  []Point
+		want   map[string]wantSnake
+		// foodsLeft is how many foods are on the board after the tick
+		foodsLeft int
+	}{
+		{
+			name: "free move",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, body: []Point{{3, 5}, {4, 5}}, dir: RIGHT},
+			},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{6, 5}, length: 3},
+			},
+		},
+		{
+			name: "wall",
+			snakes: []testSnake{
+				{id: "a", head: Point{9, 5}, dir: RIGHT},
+			},
+			want: map[string]wantSnake{
+				"a": {reason: DeathOutOfRange},
+			},
+		},
+		{
+			name: "head-on into the same cell",
+			snakes: []testSnake{
+				{id: "a", head: Point{2, 5}, dir: RIGHT},
+				{id: "b", head: Point{4, 5}, dir: LEFT},
+			},
+			want: map[string]wantSnake{
+				"a": {reason: DeathHeadOn},
+				"b": {reason: DeathHeadOn},
+			},
+		},
+		{
+			name: "heads swap cells",
+			snakes: []testSnake{
+				{id: "a", head: Point{3, 5}, body: []Point{{2, 5}}, dir: RIGHT},
+				{id: "b", head: Point{4, 5}, body: []Point{{5, 5}}, dir: LEFT},
+			},
+			want: map[string]wantSnake{
+				"a": {reason: DeathHeadOn},
+				"b": {reason: DeathHeadOn},
+			},
+		},
+		{
+			name: "head into the body of a moving snake",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, body: []Point{{5, 7}, {5, 6}}, dir: UP},
+				{id: "b", head: Point{4, 6}, dir: RIGHT},
+			},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{5, 4}, length: 3},
+				"b": {reason: DeathHitSnakeBody, killer: "a"},
+			},
+		},
+		{
+			name: "head into the old head of a moving snake",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, body: []Point{{5, 6}}, dir: UP},
+				{id: "b", head: Point{4, 5}, dir: RIGHT},
+			},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{5, 4}, length: 2},
+				"b": {reason: DeathHitSnakeBody, killer: "a"},
+			},
+		},
+		{
+			name: "head into a dead snake's head",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, dir: RIGHT},
+				{id: "b", head: Point{6, 5}, dir: UP, dead: true},
+			},
+			want: map[string]wantSnake{
+				"a": {reason: DeathHitSnakeHead},
+				"b": {},
+			},
+		},
+		{
+			name: "head into a dead snake's body",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, dir: RIGHT},
+				{id: "b", head: Point{6, 4}, body: []Point{{6, 6}, {6, 5}}, dir: UP, dead: true},
+			},
+			want: map[string]wantSnake{
+				"a": {reason: DeathHitSnakeBody},
+				"b": {},
+			},
+		},
+		{
+			name: "into its own neck",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, body: []Point{{4, 4}, {4, 5}}, dir: LEFT},
+			},
+			want: map[string]wantSnake{
+				"a": {reason: DeathSelfCollision},
+			},
+		},
+		{
+			name: "into a vacated tail cell",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, body: []Point{{3, 5}, {4, 5}}, dir: RIGHT},
+				{id: "b", head: Point{2, 5}, dir: RIGHT},
+			},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{6, 5}, length: 3},
+				"b": {alive: true, head: Point{3, 5}, length: 1},
+			},
+		},
+		{
+			name: "into its own vacated tail cell",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, body: []Point{{4, 5}, {4, 4}, {5, 4}}, dir: LEFT},
+			},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{4, 5}, length: 4},
+			},
+		},
+		{
+			name: "behind a snake of length 1",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, dir: RIGHT},
+				{id: "b", head: Point{4, 5}, dir: RIGHT},
+			},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{6, 5}, length: 1},
+				"b": {alive: true, head: Point{5, 5}, length: 1},
+			},
+		},
+		{
+			name: "behind a snake of length 1 that grows",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, dir: RIGHT},
+				{id: "b", head: Point{4, 5}, dir: RIGHT},
+			},
+			foods: []Point{{6, 5}},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{6, 5}, length: 2},
+				"b": {reason: DeathHitSnakeBody, killer: "a"},
+			},
+		},
+		{
+			name: "into the tail of a snake that grows",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, body: []Point{{3, 5}, {4, 5}}, dir: RIGHT},
+				{id: "b", head: Point{2, 5}, dir: RIGHT},
+			},
+			foods: []Point{{6, 5}},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{6, 5}, length: 4},
+				"b": {reason: DeathHitSnakeBody, killer: "a"},
+			},
+		},
+		{
+			name: "uncontested food",
+			snakes: []testSnake{
+				{id: "a", head: Point{5, 5}, dir: RIGHT},
+			},
+			foods: []Point{{6, 5}, {0, 0}},
+			want: map[string]wantSnake{
+				"a": {alive: true, head: Point{6, 5}, length: 2},
+			},
+			foodsLeft: 1,
+		},
+		{
+			name: "two heads contest food",
+			snakes: []testSnake{
+				{id: "a", head: Point{4, 5}, dir: RIGHT},
+				{id: "b", head: Point{6, 5}, dir: LEFT},
+			},
+			foods: []Point{{5, 5}},
+			want: map[string]wantSnake{
+				"a": {reason: DeathHeadOn},
+				"b": {reason: DeathHeadOn},
+			},
+			foodsLeft: 1,
+		},
+		{
+			name: "a snake dying head-on keeps its tail",
+			snakes: []testSnake{
+				{id: "a", head: Point{2, 5}, body: []Point{{0, 5}, {1, 5}}, dir: RIGHT},
+				{id: "b", head: Point{4, 5}, dir: LEFT},
+				{id: "c", head: Point{0, 4}, dir: DOWN},
+			},
+			want: map[string]wantSnake{
+				"a": {reason: DeathHeadOn},
+				"b": {reason: DeathHeadOn},
+				"c": {reason: DeathHitSnakeBody, killer: "a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb, playerIds := newTestBoard(tt.snakes, tt.foods)
+			sb.moveSnakes(playerIds)
+
+			for playerId, want := range tt.want {
+				s := sb.SnakeControllers[playerId].Snake
+				if s.IsAlive != want.alive {
+					t.Fatalf("%s alive = %v (%s), want %v", playerId, s.IsAlive, s.DeathReason, want.alive)
+				}
+				if s.DeathReason != want.reason || s.Stats.Killer != want.killer {
+					t.Errorf("%s died of %q by %q, want %q by %q", playerId, s.DeathReason, s.Stats.Killer, want.reason, want.killer)
+				}
+				if want.alive && (s.SnakeHead != want.head || len(s.cells()) != want.length) {
+					t.Errorf("%s at %v with length %d, want %v with length %d", playerId, s.SnakeHead, len(s.cells()), want.head, want.length)
+				}
+			}
+			if len(sb.Foods) != tt.foodsLeft {
+				t.Errorf("%d foods left, want %d", len(sb.Foods), tt.foodsLeft)
+			}
+		})
+	}
+}
+
+func TestDeathModes(t *testing.T) {
+	// a runs into the wall, b reaches its tail cell on the next tick
+	snakes := []testSnake{
+		{id: "a", head: Point{9, 5}, body: []Point{{7, 5}, {8, 5}}, dir: RIGHT},
+		{id: "b", head: Point{7, 7}, dir: UP},
+	}
+	tests := []struct {
+		mode      string
+		foods     int
+		removed   bool
+		bSurvives bool
+	}{
+		{mode: DeathModeRemove, removed: true, bSurvives: true},
+		{mode: DeathModeFood, foods: 3, removed: true, bSurvives: true},
+		{mode: DeathModeCorpse, bSurvives: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			sb, playerIds := newTestBoard(snakes, nil)
+			sb.config = Config{DeathMode: tt.mode, CorpseTicks: 2}
+			sb.moveSnakes(playerIds)
+
+			a := sb.SnakeControllers["a"].Snake
+			if a.IsAlive || a.Removed != tt.removed || len(sb.Foods) != tt.foods {
+				t.Fatalf("a alive %v removed %v with %d foods, want removed %v with %d foods", a.IsAlive, a.Removed, len(sb.Foods), tt.removed, tt.foods)
+			}
+
+			sb.moveSnakes(playerIds)
+			b := sb.SnakeControllers["b"].Snake
+			if b.IsAlive != tt.bSurvives {
+				t.Fatalf("b alive = %v (%s), want %v", b.IsAlive, b.DeathReason, tt.bSurvives)
+			}
+		})
+	}
+}
+
+func TestCorpsesDecay(t *testing.T) {
+	sb, playerIds := newTestBoard([]testSnake{
+		{id: "a", head: Point{9, 5}, body: []Point{{8, 5}}, dir: RIGHT},
+	}, nil)
+	sb.config.CorpseTicks = 2
+	sb.moveSnakes(playerIds)
+
+	a := sb.SnakeControllers["a"].Snake
+	sb.decayCorpses()
+	if a.Removed || a.CorpseTicks != 1 {
+		t.Fatalf("after one tick removed = %v with %d ticks left", a.Removed, a.CorpseTicks)
+	}
+	sb.decayCorpses()
+	if !a.Removed || len(a.SnakeBody) != 0 {
+		t.Fatalf("after two ticks removed = %v with body %v", a.Removed, a.SnakeBody)
+	}
+}
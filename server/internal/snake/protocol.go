@@ -0,0 +1,71 @@
+package snake
+
+import (
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// Subprotocols a client can ask for when it opens the game socket. Clients
+// that ask for none get JSON, which stays around for debugging.
+const (
+	SUBPROTOCOL_JSON    = "snake.json"
+	SUBPROTOCOL_MSGPACK = "snake.msgpack"
+)
+
+// wireCodec encodes the game messages for one connection
+type wireCodec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	// FrameType is the websocket frame the encoded messages are sent in
+	FrameType() int
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return SUBPROTOCOL_JSON }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) FrameType() int                     { return websocket.TextMessage }
+
+// msgpackCodec sends the same messages as the JSON one, field names included,
+// so both protocols share the message types and their json tags
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgpackCodec() msgpackCodec {
+	h := &codec.MsgpackHandle{}
+	h.WriteExt = true
+	h.RawToString = true
+	return msgpackCodec{handle: h}
+}
+
+func (msgpackCodec) Name() string { return SUBPROTOCOL_MSGPACK }
+
+func (mc msgpackCodec) Marshal(v any) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, mc.handle).Encode(v)
+	return data, err
+}
+
+func (mc msgpackCodec) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, mc.handle).Decode(v)
+}
+
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+var codecs = map[string]wireCodec{
+	SUBPROTOCOL_JSON:    jsonCodec{},
+	SUBPROTOCOL_MSGPACK: newMsgpackCodec(),
+}
+
+// codecFor returns the codec of a negotiated subprotocol, JSON when there is none
+func codecFor(subprotocol string) wireCodec {
+	if c, ok := codecs[subprotocol]; ok {
+		return c
+	}
+	return jsonCodec{}
+}
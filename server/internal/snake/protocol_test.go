@@ -0,0 +1,290 @@
+package snake
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func sendTestMessage(t *testing.T, client *websocket.Conn, msg any) {
+	t.Helper()
+	c := codecFor(client.Subprotocol())
+	data, err := c.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WriteMessage(c.FrameType(), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestMessage[T any](t *testing.T, client *websocket.Conn) Envelope[T] {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame, data, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	c := codecFor(client.Subprotocol())
+	if frame != c.FrameType() {
+		t.Fatalf("got frame type %d, want %d for %s", frame, c.FrameType(), c.Name())
+	}
+	var msg Envelope[T]
+	if err := c.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestCodecFor(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		name        string
+		frame       int
+	}{
+		{SUBPROTOCOL_MSGPACK, SUBPROTOCOL_MSGPACK, websocket.BinaryMessage},
+		{SUBPROTOCOL_JSON, SUBPROTOCOL_JSON, websocket.TextMessage},
+		{"", SUBPROTOCOL_JSON, websocket.TextMessage},
+		{"snake.xml", SUBPROTOCOL_JSON, websocket.TextMessage},
+	}
+	for _, tt := range tests {
+		c := codecFor(tt.subprotocol)
+		if c.Name() != tt.name || c.FrameType() != tt.frame {
+			t.Errorf("codecFor(%q) = %v with frame %d, want %v with frame %d", tt.subprotocol, c.Name(), c.FrameType(), tt.name, tt.frame)
+		}
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	head, score := Point{X: 4, Y: 2}, 7
+	sent := Envelope[DeltaUpdate]{
+		Type:    MessageDelta,
+		Version: PROTOCOL_VERSION,
+		Seq:     9,
+		Payload: DeltaUpdate{
+			Tick: 42,
+			Ack:  3,
+			BoardDelta: &BoardDelta{
+				Full:         []Snake{{SnakeIdentity: SnakeIdentity{PlayerId: "b", Name: "bob"}, SnakeHead: Point{1, 1}, SnakeBody: []Point{{0, 1}}, Direction: RIGHT, IsAlive: true}},
+				Snakes:       []SnakeDelta{{PlayerId: "a", Head: &head, TailRemoved: 1, Score: &score}},
+				Removed:      []string{"c"},
+				FoodsAdded:   []Food{{Position: Point{9, 9}, Value: 1}},
+				FoodsRemoved: []Food{{Position: Point{4, 2}, Value: 1}},
+			},
+		},
+	}
+
+	for _, name := range []string{SUBPROTOCOL_JSON, SUBPROTOCOL_MSGPACK} {
+		t.Run(name, func(t *testing.T) {
+			c := codecFor(name)
+			data, err := c.Marshal(sent)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the embedded delta is flattened into the payload like in JSON
+			var fields Envelope[map[string]any]
+			if err := c.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if fields.Payload["tick"] == nil || fields.Payload["removed"] == nil || fields.Payload["BoardDelta"] != nil {
+				t.Fatalf("payload fields = %v", fields.Payload)
+			}
+
+			var envelope Envelope[noPayload]
+			if err := c.Unmarshal(data, &envelope); err != nil || envelope.Type != MessageDelta || envelope.Seq != 9 {
+				t.Fatalf("envelope = %+v, %v", envelope, err)
+			}
+			got, err := decodePayload[DeltaUpdate](c, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, sent) {
+				t.Fatalf("got %+v\nwant %+v", got, sent)
+			}
+		})
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	tests := []struct {
+		name      string
+		hello     any
+		role      string
+		inSession bool
+		// the connection holding the session has dropped
+		dropped   bool
+		wantCode  string
+		wantClose int
+		wantErr   error
+	}{
+		{name: "hello", hello: Envelope[noPayload]{Type: MessageHello, Version: PROTOCOL_VERSION, Seq: 1}},
+		{name: "no hello", hello: Envelope[PlayerMove]{Type: MessageMove, Version: PROTOCOL_VERSION, Seq: 1, Payload: PlayerMove{UP}}, wantCode: ErrorHandshakeRequired, wantClose: websocket.CloseProtocolError},
+		{name: "other version", hello: Envelope[noPayload]{Type: MessageHello, Version: PROTOCOL_VERSION + 1, Seq: 1}, wantCode: ErrorUnsupportedVersion, wantClose: websocket.CloseProtocolError},
+		{name: "not an envelope", hello: "hello", wantCode: ErrorInvalidMessage, wantClose: websocket.CloseProtocolError},
+		// the player is already in the match
+		{name: "no resume token", inSession: true, hello: Envelope[Hello]{Type: MessageHello, Version: PROTOCOL_VERSION, Seq: 1}, wantCode: ErrorInvalidResumeToken, wantClose: websocket.ClosePolicyViolation, wantErr: ErrResumeTokenRequired},
+		// spectators can't take over a session, so they get no token
+		{name: "spectator", role: RoleSpectator, inSession: true, hello: Envelope[noPayload]{Type: MessageHello, Version: PROTOCOL_VERSION, Seq: 1}},
+		{name: "no resume token after a drop", inSession: true, dropped: true, hello: Envelope[Hello]{Type: MessageHello, Version: PROTOCOL_VERSION, Seq: 1}},
+		{name: "wrong resume token", inSession: true, hello: Envelope[Hello]{Type: MessageHello, Version: PROTOCOL_VERSION, Seq: 1, Payload: Hello{ResumeToken: "guess"}}, wantCode: ErrorInvalidResumeToken, wantClose: websocket.ClosePolicyViolation, wantErr: ErrInvalidResumeToken},
+	}
+
+	for _, subprotocol := range []string{SUBPROTOCOL_JSON, SUBPROTOCOL_MSGPACK} {
+		for _, tt := range tests {
+			t.Run(subprotocol+"/"+tt.name, func(t *testing.T) {
+				matchId := "m-handshake-" + subprotocol
+				t.Cleanup(func() { forgetSessions(matchId) })
+				if tt.inSession {
+					holder, _ := servePlayerConn(t, subprotocol, true)
+					claimSession(matchId, "a", "", holder)
+					if tt.dropped {
+						holder.close(DisconnectClosed)
+					}
+				}
+				pc, client := servePlayerConn(t, subprotocol, true)
+				pc.role = cmp.Or(tt.role, RolePlayer)
+				result := make(chan error, 1)
+				go func() { result <- pc.handshake("a", matchId) }()
+				sendTestMessage(t, client, tt.hello)
+
+				if tt.wantCode == "" {
+					welcome := readTestMessage[Welcome](t, client)
+					want := Welcome{ProtocolVersion: PROTOCOL_VERSION, Encoding: subprotocol, PlayerId: "a", MatchId: matchId, Role: pc.role, ResumeToken: welcome.Payload.ResumeToken, Resumed: tt.dropped}
+					if welcome.Type != MessageWelcome || welcome.Seq != 1 || welcome.Payload != want || (want.ResumeToken == "") != (pc.role == RoleSpectator) {
+						t.Fatalf("welcome = %+v", welcome)
+					}
+					if err := <-result; err != nil {
+						t.Fatalf("handshake failed: %v", err)
+					}
+					return
+				}
+
+				notice := readTestMessage[ErrorNotice](t, client)
+				if notice.Type != MessageError || notice.Payload.Code != tt.wantCode {
+					t.Fatalf("got %+v, want a %s error", notice, tt.wantCode)
+				}
+				_, _, err := client.ReadMessage()
+				if !websocket.IsCloseError(err, tt.wantClose) {
+					t.Fatalf("connection ended with %v, want close code %d", err, tt.wantClose)
+				}
+				err = <-result
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) ||
+					tt.wantErr == nil && (err == nil || !strings.Contains(err.Error(), tt.wantCode)) {
+					t.Fatalf("handshake error = %v, want %s", err, tt.wantCode)
+				}
+			})
+		}
+	}
+}
+
+func TestHandlePlayerInputErrors(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.StartGame("m1", "snake", []string{"a", "b"})
+	ss.AddPlayer("m1", "a")
+
+	pc, client := servePlayerConn(t, SUBPROTOCOL_MSGPACK, true)
+	go func() {
+		for {
+			_, input, err := pc.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			ss.handlePlayerInput("m1", "a", pc, input)
+		}
+	}()
+
+	move := func(seq int64, dir Direction) Envelope[PlayerMove] {
+		return Envelope[PlayerMove]{Type: MessageMove, Version: PROTOCOL_VERSION, Seq: seq, Payload: PlayerMove{dir}}
+	}
+	tests := []struct {
+		name     string
+		msg      any
+		wantSeq  int64
+		wantCode string
+	}{
+		{name: "not an envelope", msg: []int{1, 2}, wantCode: ErrorInvalidMessage},
+		{name: "other version", msg: Envelope[noPayload]{Type: MessageResync, Version: PROTOCOL_VERSION + 1, Seq: 3}, wantSeq: 3, wantCode: ErrorUnsupportedVersion},
+		{name: "unknown type", msg: Envelope[noPayload]{Type: "jump", Version: PROTOCOL_VERSION, Seq: 4}, wantSeq: 4, wantCode: ErrorUnknownType},
+		{name: "bad payload", msg: Envelope[map[string]int]{Type: MessageMove, Version: PROTOCOL_VERSION, Seq: 5, Payload: map[string]int{"direction": 1}}, wantSeq: 5, wantCode: ErrorInvalidPayload},
+		{name: "unknown direction", msg: move(6, "SIDEWAYS"), wantSeq: 6, wantCode: ErrorInvalidPayload},
+		{name: "empty chat", msg: Envelope[PlayerChat]{Type: MessageChat, Version: PROTOCOL_VERSION, Seq: 7}, wantSeq: 7, wantCode: ErrorInvalidPayload},
+		// the valid move 8 is answered by nothing, so its replay is the next reply
+		{name: "stale move", msg: []any{move(8, UP), move(8, DOWN)}, wantSeq: 8, wantCode: ErrorMoveRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msgs, ok := tt.msg.([]any); ok {
+				for _, msg := range msgs {
+					sendTestMessage(t, client, msg)
+				}
+			} else {
+				sendTestMessage(t, client, tt.msg)
+			}
+			reply := readTestMessage[ErrorNotice](t, client)
+			if reply.Type != MessageError || reply.Seq != tt.wantSeq || reply.Payload.Code != tt.wantCode {
+				t.Fatalf("got %+v, want %s error for seq %d", reply, tt.wantCode, tt.wantSeq)
+			}
+		})
+	}
+}
+
+func TestProtocolSchema(t *testing.T) {
+	schema := ProtocolSchema()
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("schema doesn't encode: %v", err)
+	}
+	defs := schema["$defs"].(map[string]any)
+
+	for name, specs := range map[string][]messageSpec{"ClientMessage": clientMessages, "ServerMessage": serverMessages} {
+		oneOf := defs[name].(map[string]any)["oneOf"].([]any)
+		if len(oneOf) != len(specs) {
+			t.Fatalf("%s has %d messages, want %d", name, len(oneOf), len(specs))
+		}
+		for i, spec := range specs {
+			envelope := oneOf[i].(map[string]any)
+			msgType := envelope["properties"].(map[string]any)["type"].(map[string]any)["const"]
+			_, hasPayload := envelope["properties"].(map[string]any)["payload"]
+			if msgType != spec.Type || hasPayload != (spec.Payload != nil) {
+				t.Errorf("%s message %d is %v with payload %v", name, i, msgType, hasPayload)
+			}
+		}
+	}
+
+	// a payload without required fields can be left out
+	for i, want := range map[int]bool{0: false, 1: true} {
+		envelope := defs["ClientMessage"].(map[string]any)["oneOf"].([]any)[i].(map[string]any)
+		if required := envelope["required"].([]string); slices.Contains(required, "payload") != want {
+			t.Errorf("%s requires %v", clientMessages[i].Type, required)
+		}
+	}
+
+	// the embedded delta's fields are part of the delta payload
+	delta := defs["DeltaUpdate"].(map[string]any)
+	properties := delta["properties"].(map[string]any)
+	for _, field := range []string{"tick", "ack", "full", "snakes", "removed", "foodsAdded", "foodsRemoved"} {
+		if properties[field] == nil {
+			t.Errorf("DeltaUpdate has no %s property", field)
+		}
+	}
+	if required := delta["required"].([]string); !slices.Equal(required, []string{"tick", "ack"}) {
+		t.Errorf("DeltaUpdate requires %v", required)
+	}
+
+	direction := defs["PlayerMove"].(map[string]any)["properties"].(map[string]any)["direction"].(map[string]any)
+	if !reflect.DeepEqual(direction["enum"], []any{UP, DOWN, LEFT, RIGHT}) {
+		t.Errorf("direction schema = %v", direction)
+	}
+	// json:"-" fields are left out
+	if _, ok := defs["Snake"].(map[string]any)["properties"].(map[string]any)["Stats"]; ok {
+		t.Error("Snake schema lists Stats")
+	}
+}
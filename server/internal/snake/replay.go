@@ -0,0 +1,235 @@
+package snake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"game-server/internal/store"
+)
+
+// REPLAY_FORMAT_VERSION is bumped whenever a change to the simulation makes
+// older recordings play out differently
+const REPLAY_FORMAT_VERSION = 1
+
+var (
+	ErrReplayVersion  = errors.New("replay was recorded by another version of the game")
+	ErrReplayMismatch = errors.New("replay doesn't match the board its seed lays out")
+)
+
+// Recording holds everything needed to play a match again: how the board
+// was laid out, who joined when and every turn a snake took
+type Recording struct {
+	Version     int      `json:"version"`
+	MatchId     string   `json:"matchId"`
+	GameId      string   `json:"gameId"`
+	Players     []string `json:"players"`
+	Seed        uint64   `json:"seed"`
+	DeathMode   string   `json:"deathMode"`
+	CorpseTicks int      `json:"corpseTicks"`
+	// The initial board, it follows from the seed and is checked against it
+	Width     int              `json:"width"`
+	Height    int              `json:"height"`
+	Obstacles []Obstacle       `json:"obstacles"`
+	Spawns    map[string]Point `json:"spawns"`
+	Foods     []Food           `json:"foods"`
+
+	Joins  []RecordedJoin  `json:"joins"`
+	Inputs []RecordedInput `json:"inputs"`
+	// Ticks is the last tick of the match, Reason why it ended
+	Ticks       int64          `json:"ticks"`
+	Reason      string         `json:"reason"`
+	FinalScores map[string]int `json:"finalScores"`
+}
+
+// RecordedJoin is a snake added after Tick, before the next step
+type RecordedJoin struct {
+	Tick int64 `json:"tick"`
+	SnakeIdentity
+}
+
+// RecordedInput is a turn a snake took in the step of Tick
+type RecordedInput struct {
+	Tick      int64     `json:"tick"`
+	PlayerId  string    `json:"playerId"`
+	Direction Direction `json:"direction"`
+}
+
+// recording copies what the board recorded so far
+func (sb *SnakeBoard) recording() *Recording {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	scores := make(map[string]int, len(sb.SnakeControllers))
+	for playerId, sc := range sb.SnakeControllers {
+		scores[playerId] = sc.Snake.Score.Value
+	}
+	return &Recording{
+		Version:     REPLAY_FORMAT_VERSION,
+		Seed:        sb.seed,
+		DeathMode:   sb.config.DeathMode,
+		CorpseTicks: sb.config.CorpseTicks,
+		Width:       sb.Width,
+		Height:      sb.Height,
+		Obstacles:   slices.Clone(sb.Obstacles),
+		Spawns:      maps.Clone(sb.spawns),
+		Foods:       slices.Clone(sb.initialFoods),
+		Joins:       slices.Clone(sb.joins),
+		Inputs:      slices.Clone(sb.inputs),
+		Ticks:       sb.Tick,
+		FinalScores: scores,
+	}
+}
+
+// recordingOf copies what a running match recorded so far, nil when the
+// match isn't running
+func (ss *SnakeService) recordingOf(matchId string) *Recording {
+	ss.mu.RLock()
+	sb, ok := ss.SnakeBoards[matchId]
+	gameId := ss.MatchGames[matchId]
+	players := ss.MatchPlayers[matchId]
+	ss.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	rec := sb.recording()
+	rec.MatchId = matchId
+	rec.GameId = gameId
+	rec.Players = players
+	return rec
+}
+
+// saveReplay stores the recording of a match that just ended
+func (ss *SnakeService) saveReplay(rec *Recording) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("Failed to encode replay of match %s: %v", rec.MatchId, err)
+		return
+	}
+	err = ss.replayStore.SaveReplay(store.MatchReplay{
+		MatchId:    rec.MatchId,
+		GameId:     rec.GameId,
+		Data:       data,
+		RecordedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to save replay of match %s: %v", rec.MatchId, err)
+	}
+}
+
+// LoadRecording returns store.ErrNotFound when the match has no replay
+func (ss *SnakeService) LoadRecording(matchId string) (*Recording, error) {
+	replay, err := ss.replayStore.LoadReplay(matchId)
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(replay.Data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode replay: %v", err)
+	}
+	if rec.Version != REPLAY_FORMAT_VERSION {
+		return nil, ErrReplayVersion
+	}
+	return &rec, nil
+}
+
+// Simulator plays a recording back step by step. It runs the board code of
+// a live match on the recorded seed, joins and turns, so every tick ends up
+// exactly as it was played.
+type Simulator struct {
+	recording *Recording
+	board     *SnakeBoard
+	nextJoin  int
+	nextInput int
+}
+
+func NewSimulator(rec *Recording) (*Simulator, error) {
+	s := &Simulator{recording: rec}
+	s.reset()
+
+	board := s.board
+	sameObstacles := slices.EqualFunc(board.Obstacles, rec.Obstacles, func(a, b Obstacle) bool {
+		return slices.Equal(a.Object, b.Object)
+	})
+	if !sameObstacles || !maps.Equal(board.spawns, rec.Spawns) || !slices.Equal(board.Foods, rec.Foods) ||
+		board.Width != rec.Width || board.Height != rec.Height {
+		return nil, ErrReplayMismatch
+	}
+	return s, nil
+}
+
+// reset lays out the board of the first tick again
+func (s *Simulator) reset() {
+	rec := s.recording
+	cfg := Config{DeathMode: rec.DeathMode, CorpseTicks: rec.CorpseTicks}
+	s.board = NewSnakeBoard(cfg, RulesFor(rec.GameId), rec.Seed, rec.Players)
+	s.nextJoin = 0
+	s.nextInput = 0
+	s.addJoins()
+}
+
+// addJoins adds the snakes that joined after the current tick, so they are
+// on the board before the next step just like in the match
+func (s *Simulator) addJoins() {
+	rec := s.recording
+	for s.nextJoin < len(rec.Joins) && rec.Joins[s.nextJoin].Tick <= s.board.Tick {
+		s.board.AddPlayer(rec.Joins[s.nextJoin].SnakeIdentity)
+		s.nextJoin++
+	}
+}
+
+func (s *Simulator) Tick() int64 {
+	return s.board.Tick
+}
+
+// Done reports whether the last tick of the match has been played
+func (s *Simulator) Done() bool {
+	return s.board.Tick >= s.recording.Ticks
+}
+
+// Step plays the next tick
+func (s *Simulator) Step() int64 {
+	rec := s.recording
+	// the recorded turn was legal then, so it is the one taken now
+	next := s.board.Tick + 1
+	for s.nextInput < len(rec.Inputs) && rec.Inputs[s.nextInput].Tick <= next {
+		input := rec.Inputs[s.nextInput]
+		if err := s.board.ExecutePlayerMovement(input.PlayerId, input.Direction, 0); err != nil {
+			log.Printf("Replay of match %s lost an input at tick %d: %v", rec.MatchId, input.Tick, err)
+		}
+		s.nextInput++
+	}
+	tick := s.board.Step(rec.Players)
+	s.addJoins()
+	return tick
+}
+
+// SeekTo plays up to tick, going back starts over from the first tick. The
+// events of the ticks skipped are dropped.
+func (s *Simulator) SeekTo(tick int64) {
+	tick = min(max(tick, 0), s.recording.Ticks)
+	if tick < s.board.Tick {
+		s.reset()
+	}
+	for s.board.Tick < tick {
+		s.Step()
+	}
+	s.board.DrainEvents()
+}
+
+// Diverged reports final scores that differ from the recorded ones, only
+// meaningful once Done
+func (s *Simulator) Diverged() bool {
+	for playerId, score := range s.recording.FinalScores {
+		sc, ok := s.board.SnakeControllers[playerId]
+		if !ok || sc.Snake.Score.Value != score {
+			return true
+		}
+	}
+	return false
+}
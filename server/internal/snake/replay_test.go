@@ -0,0 +1,202 @@
+package snake
+
+import (
+	"encoding/json"
+	"errors"
+	"game-server/internal/store"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+const replayTestTicks = 400
+
+// replayTestSnake is what a replay has to reproduce of a snake, the wall
+// clock times are left out
+type replayTestSnake struct {
+	Head        Point
+	Body        []Point
+	Direction   Direction
+	Score       int
+	Alive       bool
+	DeathReason string
+	CorpseTicks int
+	Removed     bool
+}
+
+type replayTestBoard struct {
+	Tick   int64
+	Snakes map[string]replayTestSnake
+	Foods  []Food
+}
+
+func replayTestBoardOf(sb *SnakeBoard) replayTestBoard {
+	board := replayTestBoard{Tick: sb.Tick, Snakes: make(map[string]replayTestSnake), Foods: slices.Clone(sb.Foods)}
+	for playerId, sc := range sb.SnakeControllers {
+		s := sc.Snake
+		board.Snakes[playerId] = replayTestSnake{
+			Head:        s.SnakeHead,
+			Body:        slices.Clone(s.SnakeBody),
+			Direction:   s.Direction,
+			Score:       s.Score.Value,
+			Alive:       s.IsAlive,
+			DeathReason: s.DeathReason,
+			CorpseTicks: s.CorpseTicks,
+			Removed:     s.Removed,
+		}
+	}
+	return board
+}
+
+// playRecordedMatch plays a seeded four player match with random turns, d
+// joins late. It returns the recording as it comes back from the store and
+// the board after every tick.
+func playRecordedMatch(t *testing.T) (*Recording, []replayTestBoard) {
+	t.Helper()
+	players := []string{"a", "b", "c", "d"}
+	sb := NewSnakeBoard(Config{DeathMode: DeathModeCorpse, CorpseTicks: 5}, RulesFor("four-snake-game"), 42, players)
+	for _, playerId := range players[:3] {
+		sb.AddPlayer(SnakeIdentity{PlayerId: playerId})
+	}
+	boards := []replayTestBoard{replayTestBoardOf(sb)}
+
+	turns := rand.New(rand.NewPCG(7, 7))
+	directions := []Direction{UP, DOWN, LEFT, RIGHT}
+	for range replayTestTicks {
+		for _, playerId := range players {
+			if turns.IntN(8) == 0 {
+				// illegal turns are refused and not recorded
+				sb.ExecutePlayerMovement(playerId, directions[turns.IntN(len(directions))], 0)
+			}
+		}
+		if tick := sb.Step(players); tick == 22 {
+			sb.AddPlayer(SnakeIdentity{PlayerId: "d"})
+		}
+		boards = append(boards, replayTestBoardOf(sb))
+	}
+
+	rec := sb.recording()
+	rec.GameId = "four-snake-game"
+	rec.Players = players
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Recording
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	return &stored, boards
+}
+
+func TestSimulatorReplaysTheMatch(t *testing.T) {
+	rec, boards := playRecordedMatch(t)
+	if len(rec.Inputs) == 0 || len(rec.Joins) != 4 {
+		t.Fatalf("recorded %d inputs and %d joins, the test needs turns and a late join", len(rec.Inputs), len(rec.Joins))
+	}
+
+	sim, err := NewSimulator(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayTestBoardOf(sim.board); !reflect.DeepEqual(got, boards[0]) {
+		t.Fatalf("opening board = %+v, want %+v", got, boards[0])
+	}
+	for !sim.Done() {
+		tick := sim.Step()
+		if got := replayTestBoardOf(sim.board); !reflect.DeepEqual(got, boards[tick]) {
+			t.Fatalf("tick %d: replayed %+v, played %+v", tick, got, boards[tick])
+		}
+	}
+
+	if sim.Tick() != replayTestTicks || sim.Diverged() {
+		t.Fatalf("replay ended at tick %d, diverged %v", sim.Tick(), sim.Diverged())
+	}
+	scored := false
+	for playerId, score := range rec.FinalScores {
+		scored = scored || score > 0
+		if got := sim.board.SnakeControllers[playerId].Snake.Score.Value; got != score {
+			t.Errorf("%s ended with %d, recorded %d", playerId, got, score)
+		}
+	}
+	if !scored {
+		t.Error("nobody scored, the food isn't covered")
+	}
+}
+
+func TestSimulatorSeeksBothWays(t *testing.T) {
+	rec, boards := playRecordedMatch(t)
+	sim, err := NewSimulator(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		seek int64
+		want int64
+	}{
+		{seek: 300, want: 300},
+		{seek: 57, want: 57},
+		{seek: 21, want: 21},
+		// d joins after tick 22
+		{seek: 22, want: 22},
+		{seek: 301, want: 301},
+		{seek: -5, want: 0},
+		{seek: replayTestTicks + 100, want: replayTestTicks},
+	}
+	for _, tt := range tests {
+		sim.SeekTo(tt.seek)
+		if got := replayTestBoardOf(sim.board); !reflect.DeepEqual(got, boards[tt.want]) {
+			t.Fatalf("seek to %d: got %+v, want %+v", tt.seek, got, boards[tt.want])
+		}
+		if events := sim.board.DrainEvents(); len(events) != 0 {
+			t.Fatalf("seek to %d left %d events of the skipped ticks", tt.seek, len(events))
+		}
+	}
+}
+
+func TestNewSimulatorChecksTheSeed(t *testing.T) {
+	rec, _ := playRecordedMatch(t)
+	rec.Seed++
+	if _, err := NewSimulator(rec); !errors.Is(err, ErrReplayMismatch) {
+		t.Fatalf("NewSimulator with another seed: %v, want %v", err, ErrReplayMismatch)
+	}
+}
+
+func TestSaveAndLoadRecording(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.StartGame("m-recorded", "snake", []string{"a", "b"})
+	ss.AddPlayer("m-recorded", "a")
+	ss.AddPlayer("m-recorded", "b")
+	for range 2 * MOVE_INTERVAL_TICKS {
+		ss.Tick("m-recorded")
+	}
+	recorded := ss.recordingOf("m-recorded")
+	recorded.Reason = GameOverNoPlayers
+	ss.saveReplay(recorded)
+	if ss.recordingOf("m-unknown") != nil {
+		t.Fatal("recorded a match that isn't running")
+	}
+
+	rec, err := ss.LoadRecording("m-recorded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.MatchId != "m-recorded" || rec.GameId != "snake" || !slices.Equal(rec.Players, []string{"a", "b"}) ||
+		rec.Reason != GameOverNoPlayers || rec.Ticks != 2*MOVE_INTERVAL_TICKS {
+		t.Fatalf("recording = %+v", rec)
+	}
+	if _, err := NewSimulator(rec); err != nil {
+		t.Fatalf("NewSimulator of a saved recording: %v", err)
+	}
+
+	if _, err := ss.LoadRecording("m-unknown"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("LoadRecording of unknown match: %v, want %v", err, store.ErrNotFound)
+	}
+	ss.replayStore.SaveReplay(store.MatchReplay{MatchId: "m-old", GameId: "snake", Data: []byte(`{"version":0}`), RecordedAt: time.Now()})
+	if _, err := ss.LoadRecording("m-old"); !errors.Is(err, ErrReplayVersion) {
+		t.Fatalf("LoadRecording of an old recording: %v, want %v", err, ErrReplayVersion)
+	}
+}
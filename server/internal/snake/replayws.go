@@ -0,0 +1,212 @@
+package snake
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"game-server/internal/events"
+	"game-server/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// REPLAY_SPEEDS are the speeds a replay can play at, in ticks per tick of a
+// live match
+var REPLAY_SPEEDS = []int{1, 2, 4}
+
+// replayControl is a speed change or a seek sent by the viewer
+type replayControl struct {
+	speed int
+	seek  *int64
+}
+
+// ReplayHandler plays a finished match back to a viewer. The viewer gets the
+// same messages the players did and can change the speed or seek.
+func (ss *SnakeService) ReplayHandler(c *gin.Context) {
+	matchId := c.Query("matchId")
+	if matchId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "matchId required"})
+		return
+	}
+	viewerId := "viewer-" + uuid.NewString()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println("Upgrading error:", err)
+		return
+	}
+	pc := newPlayerConn(conn, codecFor(conn.Subprotocol()), ss.config)
+	defer pc.closeWith(websocket.CloseNormalClosure, "")
+	pc.role = RoleViewer
+
+	rec, err := ss.LoadRecording(matchId)
+	if errors.Is(err, store.ErrNotFound) {
+		pc.closeWith(CloseMatchNotFound, "match has no replay")
+		return
+	}
+	var sim *Simulator
+	if err == nil {
+		sim, err = NewSimulator(rec)
+	}
+	if err != nil {
+		log.Printf("Failed to load replay of match %s: %v", matchId, err)
+		pc.closeWith(websocket.CloseInternalServerErr, "replay can't be played")
+		return
+	}
+
+	if err := pc.handshake(viewerId, matchId); err != nil {
+		log.Printf("Handshake with viewer %s failed: %v", viewerId, err)
+		return
+	}
+	err = pc.send(MessageReplayInfo, 0, ReplayInfo{
+		MatchId:        matchId,
+		GameId:         rec.GameId,
+		Players:        rec.Players,
+		Ticks:          rec.Ticks,
+		TickIntervalMs: TICK_INTERVAL.Milliseconds(),
+		Speed:          REPLAY_SPEEDS[0],
+	})
+	if err != nil {
+		return
+	}
+	log.Printf("Viewer %s watching the replay of match %s using %s", viewerId, matchId, pc.codec.Name())
+
+	controls := make(chan replayControl, SEND_QUEUE_SIZE)
+	go playReplay(pc, sim, controls)
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			pc.readFailed(err)
+			log.Printf("Viewer %s left the replay of match %s (%s): %v", viewerId, matchId, pc.disconnectReason(), err)
+			return
+		}
+		pc.keepAlive()
+		handleReplayInput(viewerId, pc, controls, message)
+	}
+}
+
+func handleReplayInput(viewerId string, pc *playerConn, controls chan<- replayControl, input []byte) {
+	msg, ok := pc.decodeEnvelope(viewerId, input)
+	if !ok {
+		return
+	}
+
+	var control replayControl
+	var err error
+	switch msg.Type {
+	case MessageReplaySpeed:
+		var speed Envelope[ReplaySpeed]
+		if speed, err = decodePayload[ReplaySpeed](pc.codec, input); err == nil {
+			if !slices.Contains(REPLAY_SPEEDS, speed.Payload.Speed) {
+				err = fmt.Errorf("speed must be one of %v", REPLAY_SPEEDS)
+			}
+			control.speed = speed.Payload.Speed
+		}
+	case MessageReplaySeek:
+		var seek Envelope[ReplaySeek]
+		if seek, err = decodePayload[ReplaySeek](pc.codec, input); err == nil {
+			control.seek = &seek.Payload.Tick
+		}
+	case MessageResync:
+		var tick int64 = -1
+		control.seek = &tick
+	case MessageMove, MessageChat:
+		err = &protocolError{code: ErrorForbidden, message: fmt.Sprintf("%ss can't send %s", pc.role, msg.Type)}
+	default:
+		err = &protocolError{code: ErrorUnknownType, message: fmt.Sprintf("unknown message type %q", msg.Type)}
+	}
+
+	if err != nil {
+		pc.reject(viewerId, msg, err)
+		return
+	}
+	select {
+	case controls <- control:
+	case <-pc.done:
+	}
+}
+
+// playReplay steps the simulator at the chosen speed until the viewer leaves.
+// The end of the match is announced with a game over and the replay pauses
+// there until the viewer seeks back. A seek to a negative tick resends the
+// current one.
+func playReplay(pc *playerConn, sim *Simulator, controls <-chan replayControl) {
+	rec := sim.recording
+	speed := REPLAY_SPEEDS[0]
+	ticker := time.NewTicker(TICK_INTERVAL / time.Duration(speed))
+	defer ticker.Stop()
+
+	sendReplaySnapshot(pc, sim)
+	over, checked := false, false
+	for {
+		ticks := ticker.C
+		if sim.Done() {
+			if !over {
+				if !checked && sim.Diverged() {
+					log.Printf("Replay of match %s diverged from the recorded final scores", rec.MatchId)
+				}
+				checked = true
+				pc.send(MessageGameOver, 0, GameOverNotice{
+					Tick:      sim.Tick(),
+					Reason:    rec.Reason,
+					Standings: standingsOf(sim.board.Results(rec.Players)),
+				})
+				over = true
+			}
+			// paused at the end, a nil channel never fires
+			ticks = nil
+		} else {
+			over = false
+		}
+
+		select {
+		case <-pc.done:
+			return
+		case control := <-controls:
+			if control.speed != 0 && control.speed != speed {
+				speed = control.speed
+				ticker.Reset(TICK_INTERVAL / time.Duration(speed))
+			}
+			if control.seek != nil {
+				if *control.seek >= 0 {
+					sim.SeekTo(*control.seek)
+				}
+				sendReplaySnapshot(pc, sim)
+			}
+		case <-ticks:
+			tick := sim.Step()
+			for _, e := range sim.board.DrainEvents() {
+				if e.Type == events.PlayerDied {
+					pc.send(MessagePlayerDied, 0, playerDiedNotice(e))
+				}
+			}
+			sendReplayState(pc, MessageDelta, DeltaUpdate{Tick: tick, BoardDelta: sim.board.Delta()})
+		}
+	}
+}
+
+// sendReplaySnapshot shows the whole board, deltas go on from it
+func sendReplaySnapshot(pc *playerConn, sim *Simulator) {
+	sim.board.Delta()
+	sendReplayState(pc, MessageSnapshot, StateUpdate{
+		Tick:  sim.Tick(),
+		State: sim.board.GetSnakeBoard(""),
+	})
+}
+
+func sendReplayState(pc *playerConn, msgType string, state any) {
+	data, err := pc.codec.Marshal(Envelope[any]{Type: msgType, Version: PROTOCOL_VERSION, Payload: state})
+	if err != nil {
+		log.Printf("Error marshalling %s: %v", msgType, err)
+		return
+	}
+	// every delta builds on the one before, so none may be dropped
+	pc.queue(data)
+}
@@ -0,0 +1,53 @@
+package snake
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// readReplayUntil skips replay messages until one of msgType arrives. Every
+// state message and the game over carry a tick.
+func readReplayUntil(t *testing.T, viewer *websocket.Conn, msgType string) Envelope[StateUpdate] {
+	t.Helper()
+	for {
+		if msg := readTestMessage[StateUpdate](t, viewer); msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func TestReplayPausesAtTheEnd(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.StartGame("m-replayed", "snake", []string{"a", "b"})
+	ss.AddPlayer("m-replayed", "a")
+	ss.AddPlayer("m-replayed", "b")
+	for range 2 * MOVE_INTERVAL_TICKS {
+		ss.Tick("m-replayed")
+	}
+	rec := ss.recordingOf("m-replayed")
+	rec.Reason = GameOverNoPlayers
+	ss.saveReplay(rec)
+
+	url := serveSnakeWs(t, ss)
+	wantClose(t, dialMatch(t, url+"/ws/replay?matchId=m-unknown"), CloseMatchNotFound)
+
+	viewer, welcome := handshakeMatch(t, url+"/ws/replay?matchId=m-replayed", "")
+	if welcome.Role != RoleViewer {
+		t.Fatalf("viewer welcome = %+v", welcome)
+	}
+	sendTestMessage(t, viewer, Envelope[ReplaySpeed]{Type: MessageReplaySpeed, Version: PROTOCOL_VERSION, Seq: 2, Payload: ReplaySpeed{4}})
+	if over := readReplayUntil(t, viewer, MessageGameOver); over.Payload.Tick != rec.Ticks {
+		t.Fatalf("game over at tick %d, want %d", over.Payload.Tick, rec.Ticks)
+	}
+
+	// the finished replay is still open, seeking back plays on from there
+	sendTestMessage(t, viewer, Envelope[ReplaySeek]{Type: MessageReplaySeek, Version: PROTOCOL_VERSION, Seq: 3, Payload: ReplaySeek{3}})
+	if snapshot := readTestMessage[StateUpdate](t, viewer); snapshot.Type != MessageSnapshot || snapshot.Payload.Tick != 3 {
+		t.Fatalf("after seeking back got %s at tick %d, want a snapshot at tick 3", snapshot.Type, snapshot.Payload.Tick)
+	}
+	if delta := readTestMessage[StateUpdate](t, viewer); delta.Type != MessageDelta || delta.Payload.Tick != 4 {
+		t.Fatalf("after the snapshot got %s at tick %d, want a delta at tick 4", delta.Type, delta.Payload.Tick)
+	}
+	readReplayUntil(t, viewer, MessageGameOver)
+}
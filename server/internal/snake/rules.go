@@ -0,0 +1,50 @@
+package snake
+
+import "time"
+
+const (
+	// VictoryLastAlive ends the match when one snake is left, survivors rank first
+	VictoryLastAlive = "last_alive"
+	// VictoryHighScore ranks by score once the time limit runs out or every snake died
+	VictoryHighScore = "high_score"
+	// VictoryScoreTarget ends the match when a snake reaches the score target
+	VictoryScoreTarget = "score_target"
+
+	// Why a match ended, sent in the game_over message
+	GameOverLastAlive   = "last_alive"
+	GameOverAllDead     = "all_dead"
+	GameOverTimeLimit   = "time_limit"
+	GameOverScoreTarget = "score_target"
+	GameOverNoPlayers   = "no_players"
+
+	// Players that have not connected within this many ticks forfeit
+	JOIN_GRACE_TICKS = 100
+)
+
+// Rules decide when a match of a game mode is over and how players rank
+type Rules struct {
+	Victory string
+	// TimeLimit of zero lets the match run until another rule ends it
+	TimeLimit   time.Duration
+	ScoreTarget int
+}
+
+var GameModeRules = map[string]Rules{
+	"single-snake-game": {Victory: VictoryHighScore},
+	"snake":             {Victory: VictoryLastAlive, TimeLimit: 10 * time.Minute},
+	"four-snake-game":   {Victory: VictoryLastAlive, TimeLimit: 10 * time.Minute},
+	"10-snake-game":     {Victory: VictoryScoreTarget, TimeLimit: 15 * time.Minute, ScoreTarget: 150},
+}
+
+// RulesFor returns the rules of a game mode, last snake alive if it has none
+func RulesFor(gameId string) Rules {
+	if rules, ok := GameModeRules[gameId]; ok {
+		return rules
+	}
+	return Rules{Victory: VictoryLastAlive}
+}
+
+// timeLimitTicks converts the time limit to simulation ticks, 0 when unlimited
+func (r Rules) timeLimitTicks() int64 {
+	return int64(r.TimeLimit / TICK_INTERVAL)
+}
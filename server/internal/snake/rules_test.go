@@ -0,0 +1,238 @@
+package snake
+
+import (
+	"testing"
+	"time"
+)
+
+// ruleSnake is a joined player's snake at the end of a tick
+type ruleSnake struct {
+	id       string
+	score    int
+	dead     bool
+	diedTick int64
+}
+
+func newRulesBoard(rules Rules, tick int64, snakes []ruleSnake) *SnakeBoard {
+	sb := &SnakeBoard{
+		SnakeControllers: make(map[string]*SnakeController),
+		Width:            10,
+		Height:           10,
+		Tick:             tick,
+		rules:            rules,
+	}
+	for _, rs := range snakes {
+		s := NewSnake(SnakeIdentity{PlayerId: rs.id}, Point{})
+		s.Score.Value = rs.score
+		s.IsAlive = !rs.dead
+		s.Stats.DiedTick = rs.diedTick
+		sb.SnakeControllers[rs.id] = NewSnakeController(s)
+	}
+	return sb
+}
+
+func TestGameOver(t *testing.T) {
+	lastAlive := Rules{Victory: VictoryLastAlive}
+	timed := Rules{Victory: VictoryHighScore, TimeLimit: 10 * TICK_INTERVAL}
+	target := Rules{Victory: VictoryScoreTarget, ScoreTarget: 150}
+
+	tests := []struct {
+		name    string
+		rules   Rules
+		tick    int64
+		players []string
+		snakes  []ruleSnake
+		want    string
+	}{
+		{
+			name:    "one snake left",
+			rules:   lastAlive,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a"}, {id: "b", dead: true}},
+			want:    GameOverLastAlive,
+		},
+		{
+			name:    "two snakes left",
+			rules:   lastAlive,
+			players: []string{"a", "b", "c"},
+			snakes:  []ruleSnake{{id: "a"}, {id: "b"}, {id: "c", dead: true}},
+		},
+		{
+			name:    "a lone player plays on",
+			rules:   lastAlive,
+			players: []string{"a"},
+			snakes:  []ruleSnake{{id: "a"}},
+		},
+		{
+			name:    "waiting for a player to join",
+			rules:   lastAlive,
+			tick:    JOIN_GRACE_TICKS - 1,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a"}},
+		},
+		{
+			name:    "join grace over",
+			rules:   lastAlive,
+			tick:    JOIN_GRACE_TICKS,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a"}},
+			want:    GameOverLastAlive,
+		},
+		{
+			name:    "nobody joined yet",
+			rules:   lastAlive,
+			tick:    JOIN_GRACE_TICKS,
+			players: []string{"a", "b"},
+		},
+		{
+			name:    "every snake died",
+			rules:   timed,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a", dead: true}, {id: "b", dead: true}},
+			want:    GameOverAllDead,
+		},
+		{
+			name:    "before the time limit",
+			rules:   timed,
+			tick:    9,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a"}, {id: "b", dead: true}},
+		},
+		{
+			name:    "time limit",
+			rules:   timed,
+			tick:    10,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a"}, {id: "b"}},
+			want:    GameOverTimeLimit,
+		},
+		{
+			name:    "below the score target",
+			rules:   target,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a", score: 149}, {id: "b"}},
+		},
+		{
+			name:    "score target reached",
+			rules:   target,
+			players: []string{"a", "b"},
+			snakes:  []ruleSnake{{id: "a", score: 150}, {id: "b"}},
+			want:    GameOverScoreTarget,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := newRulesBoard(tt.rules, tt.tick, tt.snakes)
+			if got := sb.GameOver(tt.players); got != tt.want {
+				t.Errorf("GameOver = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesTimeLimitTicks(t *testing.T) {
+	if got := (Rules{TimeLimit: time.Second}).timeLimitTicks(); got != int64(time.Second/TICK_INTERVAL) {
+		t.Errorf("timeLimitTicks of 1s = %d", got)
+	}
+	if got := (Rules{}).timeLimitTicks(); got != 0 {
+		t.Errorf("timeLimitTicks without a limit = %d, want 0", got)
+	}
+}
+
+func TestResultsPlacements(t *testing.T) {
+	tests := []struct {
+		name    string
+		victory string
+		snakes  []ruleSnake
+		// want maps each player to its placement, 0 for none
+		want map[string]int
+	}{
+		{
+			name:    "last alive ranks by survival over score",
+			victory: VictoryLastAlive,
+			snakes: []ruleSnake{
+				{id: "a", score: 10, dead: true, diedTick: 5},
+				{id: "b", score: 1, dead: true, diedTick: 8},
+				{id: "c"},
+			},
+			want: map[string]int{"c": 1, "b": 2, "a": 3},
+		},
+		{
+			name:    "snakes dying in the same tick rank by score",
+			victory: VictoryLastAlive,
+			snakes: []ruleSnake{
+				{id: "a", score: 1, dead: true, diedTick: 5},
+				{id: "b", score: 3, dead: true, diedTick: 5},
+				{id: "c"},
+			},
+			want: map[string]int{"c": 1, "b": 2, "a": 3},
+		},
+		{
+			name:    "snakes dying in the same tick with equal scores tie",
+			victory: VictoryLastAlive,
+			snakes: []ruleSnake{
+				{id: "a", score: 2, dead: true, diedTick: 5},
+				{id: "b", score: 2, dead: true, diedTick: 5},
+				{id: "c", score: 2, dead: true, diedTick: 3},
+			},
+			want: map[string]int{"a": 1, "b": 1, "c": 3},
+		},
+		{
+			name:    "high score ranks survivors ahead on equal score",
+			victory: VictoryHighScore,
+			snakes: []ruleSnake{
+				{id: "a", score: 5, dead: true, diedTick: 9},
+				{id: "b", score: 5},
+				{id: "c", score: 7, dead: true, diedTick: 2},
+			},
+			want: map[string]int{"c": 1, "b": 2, "a": 3},
+		},
+		{
+			name:    "high score ties",
+			victory: VictoryScoreTarget,
+			snakes: []ruleSnake{
+				{id: "a", score: 5},
+				{id: "b", score: 5},
+				{id: "c", score: 1},
+			},
+			want: map[string]int{"a": 1, "b": 1, "c": 3},
+		},
+		{
+			name:    "players that never joined are not placed",
+			victory: VictoryHighScore,
+			snakes: []ruleSnake{
+				{id: "b", score: 2, dead: true, diedTick: 4},
+			},
+			want: map[string]int{"b": 1, "a": 0, "c": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := newRulesBoard(Rules{Victory: tt.victory}, 10, tt.snakes)
+			results := sb.Results([]string{"a", "b", "c"})
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.want))
+			}
+			last := 0
+			for _, r := range results {
+				placement := 0
+				if r.Placement != nil {
+					placement = *r.Placement
+				}
+				if placement != tt.want[r.PlayerId] {
+					t.Errorf("%s placed %d, want %d", r.PlayerId, placement, tt.want[r.PlayerId])
+				}
+				// players without a placement come last
+				if placement == 0 {
+					last = -1
+				} else if last < 0 || placement < last {
+					t.Errorf("results out of order: %+v", results)
+				} else {
+					last = placement
+				}
+			}
+		})
+	}
+}
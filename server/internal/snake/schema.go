@@ -0,0 +1,147 @@
+package snake
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// enumValues lists the allowed values of string types in the schema
+var enumValues = map[reflect.Type][]any{
+	reflect.TypeFor[Direction](): {UP, DOWN, LEFT, RIGHT},
+}
+
+// ProtocolSchema describes every message of the snake protocol as a JSON
+// Schema. It is generated from the message types, so it can't drift from them.
+func ProtocolSchema() map[string]any {
+	b := &schemaBuilder{defs: make(map[string]any)}
+	b.defs["ClientMessage"] = map[string]any{"oneOf": b.envelopes(clientMessages)}
+	b.defs["ServerMessage"] = map[string]any{"oneOf": b.envelopes(serverMessages)}
+
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "Snake WebSocket protocol",
+		"version": PROTOCOL_VERSION,
+		"oneOf": []any{
+			map[string]any{"$ref": "#/$defs/ClientMessage"},
+			map[string]any{"$ref": "#/$defs/ServerMessage"},
+		},
+		"$defs": b.defs,
+	}
+}
+
+type schemaBuilder struct {
+	defs map[string]any
+}
+
+func (b *schemaBuilder) envelopes(specs []messageSpec) []any {
+	envelopes := make([]any, 0, len(specs))
+	for _, spec := range specs {
+		properties := map[string]any{
+			"type":    map[string]any{"const": spec.Type},
+			"version": map[string]any{"const": PROTOCOL_VERSION},
+			"seq":     map[string]any{"type": "integer"},
+		}
+		required := []string{"type", "version"}
+		if spec.Payload != nil {
+			t := reflect.TypeOf(spec.Payload)
+			properties["payload"] = b.typeSchema(t)
+			// a payload without required fields can be left out
+			if def, ok := b.defs[t.Name()].(map[string]any); !ok || len(def["required"].([]string)) > 0 {
+				required = append(required, "payload")
+			}
+		}
+		envelopes = append(envelopes, map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		})
+	}
+	return envelopes
+}
+
+// typeSchema follows encoding/json, named structs end up in $defs
+func (b *schemaBuilder) typeSchema(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if values, ok := enumValues[t]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.typeSchema(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.defs[t.Name()]; !ok {
+			// reserve the name first so recursive types terminate
+			b.defs[t.Name()] = nil
+			b.defs[t.Name()] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		// nil slices are encoded as null
+		return map[string]any{"type": []string{"array", "null"}, "items": b.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	b.addFields(t, properties, &required)
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// addFields adds the json fields of a struct, embedded structs are flattened
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.typeSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package snake
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// CloseSessionReplaced closes a connection taken over by a newer one
+const CloseSessionReplaced = 4001
+
+var (
+	ErrResumeTokenRequired = errors.New("player is already in the match, resume token required")
+	ErrInvalidResumeToken  = errors.New("invalid resume token")
+)
+
+// playerSession outlives the player's connections until the match ends
+type playerSession struct {
+	token string
+	// conn is the newest connection of the player, it stays set after it drops
+	conn *playerConn
+}
+
+// matchSessions is guarded by matchConnMutex
+var matchSessions = make(map[string]map[string]*playerSession)
+
+// claimSession checks a new connection pc may speak for the player. The first
+// one gets a resume token, later ones take over the session by presenting it.
+// Without the token a session can only be taken over once its connection is
+// gone, the new connection then gets a fresh token and the old one stops
+// working.
+func claimSession(matchId, playerId, token string, pc *playerConn) (string, bool, error) {
+	matchConnMutex.Lock()
+	defer matchConnMutex.Unlock()
+
+	if session, ok := matchSessions[matchId][playerId]; ok {
+		switch {
+		case token == session.token:
+		case token != "":
+			return "", false, ErrInvalidResumeToken
+		case session.conn != nil && !session.conn.isClosed():
+			return "", false, ErrResumeTokenRequired
+		default:
+			session.token = uuid.NewString()
+		}
+		session.conn = pc
+		return session.token, true, nil
+	}
+
+	if matchSessions[matchId] == nil {
+		matchSessions[matchId] = make(map[string]*playerSession)
+	}
+	session := &playerSession{token: uuid.NewString(), conn: pc}
+	matchSessions[matchId][playerId] = session
+	return session.token, false, nil
+}
+
+// isLatestConnection reports whether no newer connection took over from pc
+func isLatestConnection(matchId, playerId string, pc *playerConn) bool {
+	matchConnMutex.RLock()
+	defer matchConnMutex.RUnlock()
+
+	session, ok := matchSessions[matchId][playerId]
+	return ok && session.conn == pc
+}
+
+func forgetSessions(matchId string) {
+	matchConnMutex.Lock()
+	defer matchConnMutex.Unlock()
+
+	delete(matchSessions, matchId)
+}
@@ -0,0 +1,50 @@
+package snake
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClaimSession(t *testing.T) {
+	const matchId, playerId = "session-test", "a"
+	t.Cleanup(func() { forgetSessions(matchId) })
+	conn := func() *playerConn {
+		pc, _ := servePlayerConn(t, SUBPROTOCOL_JSON, true)
+		return pc
+	}
+
+	first, resumed, err := claimSession(matchId, playerId, "", conn())
+	if err != nil || resumed || first == "" {
+		t.Fatalf("first claim = %q, %v, %v, want a token", first, resumed, err)
+	}
+
+	live := conn()
+	token, resumed, err := claimSession(matchId, playerId, first, live)
+	if err != nil || !resumed || token != first {
+		t.Fatalf("claim with the token = %q, %v, %v, want %q resumed", token, resumed, err, first)
+	}
+
+	// the player is still connected, only the token can take over
+	if _, _, err := claimSession(matchId, playerId, "", conn()); !errors.Is(err, ErrResumeTokenRequired) {
+		t.Fatalf("claim without a token: %v, want %v", err, ErrResumeTokenRequired)
+	}
+	if _, _, err := claimSession(matchId, playerId, "guess", conn()); !errors.Is(err, ErrInvalidResumeToken) {
+		t.Fatalf("claim with a wrong token: %v, want %v", err, ErrInvalidResumeToken)
+	}
+
+	// once the connection is gone the session is free for the taking, and
+	// the token of the old connection stops working
+	live.close(DisconnectClosed)
+	token, resumed, err = claimSession(matchId, playerId, "", conn())
+	if err != nil || !resumed || token == "" || token == first {
+		t.Fatalf("claim without a token after the drop = %q, %v, %v, want a fresh token resumed", token, resumed, err)
+	}
+	if _, _, err := claimSession(matchId, playerId, first, conn()); !errors.Is(err, ErrInvalidResumeToken) {
+		t.Fatalf("claim with the old token: %v, want %v", err, ErrInvalidResumeToken)
+	}
+
+	// other players of the match have sessions of their own
+	if _, resumed, err := claimSession(matchId, "b", "", conn()); err != nil || resumed {
+		t.Fatalf("claim for another player = %v, %v, want a new session", resumed, err)
+	}
+}
@@ -24,25 +24,58 @@ const (
 	DOWN  Direction = "DOWN"
 )
 
+// SnakeIdentity tells clients whose snake is whose
+type SnakeIdentity struct {
+	PlayerId string `json:"playerId"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+}
+
+// Snake colors by seat, enough for the largest game mode
+var snakeColors = []string{
+	"#4ade80", "#60a5fa", "#facc15", "#f87171", "#c084fc",
+	"#fb923c", "#2dd4bf", "#f472b6", "#a3e635", "#94a3b8",
+}
+
 type Snake struct {
+	SnakeIdentity
 	SnakeHead    Point     `json:"snakeHead"`
 	SnakeBody    []Point   `json:"snakeBody"`
 	Direction    Direction `json:"direction"`
 	Score        Score     `json:"score"`
 	StartingTime time.Time `json:"time"`
 	IsAlive 		bool 	`json:"isalive"`
+	DeathReason  string    `json:"deathReason,omitempty"`
+	// CorpseTicks counts down the ticks a dead snake stays on the board
+	CorpseTicks  int        `json:"corpseTicks,omitempty"`
+	// Removed snakes are dead and no longer take up any cell
+	Removed      bool       `json:"-"`
+	Stats        SnakeStats `json:"-"`
+}
+
+// SnakeStats are tracked during a match and saved with the match results
+type SnakeStats struct {
+	FoodEaten int
+	MaxLength int
+	// Killer is the player whose body this snake ran into
+	Killer string
+	DiedAt time.Time
+	// DiedTick orders deaths within a match, 0 while alive
+	DiedTick int64
 }
 
 
 
-func NewSnake() *Snake {
+func NewSnake(identity SnakeIdentity, head Point) *Snake {
 	return &Snake{
-		SnakeHead:    newRandomSnakeHead(),
+		SnakeIdentity: identity,
+		SnakeHead:    head,
 		SnakeBody:    []Point{},
 		Direction:    RIGHT,
 		Score:        Score{Value: 0},
 		StartingTime: time.Now(),
 		IsAlive: true,
+		Stats:        SnakeStats{MaxLength: 1},
 	}
 }
 
@@ -96,44 +129,6 @@ func checkFood(foods []Food, head Point) (bool, Food, int) {
 	return false, Food{}, -1
 }
 
-func checkCollision(head Point, snakeBody []Point, gameBoard *SnakeBoard) (bool, string) {
-	// Board range check
-	if (head.X < 0 || head.X >= gameBoard.Width) || (head.Y < 0 || head.Y >= gameBoard.Height) {
-		return true, "Out of range"
-	}
-
-	// Obstacle collision
-	for _, obs := range gameBoard.Obstacles {
-		for _, o := range obs.Object {
-			if head.X == o.X && head.Y == o.Y {
-				return true, "Hit Obstacle"
-			}
-		}
-	}
-
-	// Other snakes collision
-	for _, sc := range gameBoard.SnakeControllers {
-		otherSnake := sc.Snake
-		if otherSnake.SnakeHead.X == head.X && otherSnake.SnakeHead.Y == head.Y {
-			return true, "Hit other snake head"
-		}
-		for _, part := range otherSnake.SnakeBody {
-			if head.X == part.X && head.Y == part.Y {
-				return true, "Hit other snake body"
-			}
-		}
-	}
-
-	// Self body collision
-	for _, part := range snakeBody {
-		if head.X == part.X && head.Y == part.Y {
-			return true, "Self Collision"
-		}
-	}
-
-	return false, ""
-}
-
 func executeMovement(newHead Point, snake *Snake, isFood bool) {
 	if !snake.IsAlive{
 		return 
@@ -145,36 +140,41 @@ func executeMovement(newHead Point, snake *Snake, isFood bool) {
 	snake.SnakeHead = newHead
 }
 
-func (s *Snake) Movement(gameBoard *SnakeBoard) (bool, string) {
-	newHeadPosition := executeDirMovement(s.SnakeHead, s.Direction)
+// eat grows the snake onto the food cell
+func (s *Snake) eat(newHead Point, food Food) {
+	executeMovement(newHead, s, true)
+	s.Score.Value += food.Value
+	s.Stats.FoodEaten++
+	s.Stats.MaxLength = max(s.Stats.MaxLength, len(s.SnakeBody)+1)
+}
 
-	if isCollision, msg := checkCollision(newHeadPosition, s.SnakeBody, gameBoard); isCollision {
-		log.Printf("Collision detected: %s", msg)
-		s.IsAlive = false
-		return true, msg
-	}
+// die keeps the snake where it was, killer is the player whose snake it ran into
+func (s *Snake) die(reason, killer string, tick int64) {
+	log.Printf("Collision detected: %s", reason)
+	s.IsAlive = false
+	s.DeathReason = reason
+	s.Stats.Killer = killer
+	s.Stats.DiedAt = time.Now()
+	s.Stats.DiedTick = tick
+}
 
-	if isFood, food, idx := checkFood(gameBoard.Foods, newHeadPosition); isFood {
-		executeMovement(newHeadPosition, s, true)
-		s.Score.Value += food.Value
-		
-		// Remove eaten food
-		if idx >= 0 && idx < len(gameBoard.Foods) {
-			gameBoard.Foods = append(gameBoard.Foods[:idx], gameBoard.Foods[idx+1:]...)
-		}
-		return false, ""
-	}
+// cells lists every cell the snake occupies, tail first
+func (s *Snake) cells() []Point {
+	return append(append(make([]Point, 0, len(s.SnakeBody)+1), s.SnakeBody...), s.SnakeHead)
+}
 
-	executeMovement(newHeadPosition, s, false)
-	return false, ""
+func (s *Snake) remove() {
+	s.SnakeBody = []Point{}
+	s.CorpseTicks = 0
+	s.Removed = true
 }
 
-func newRandomSnakeHead() Point {
+func newRandomSnakeHead(rng *rand.Rand) Point {
 	spanWidth := 20;
 	spanHeight := 20;
 	startingPoint := Point{
-		X: rand.IntN(spanWidth),
-		Y: rand.IntN(spanHeight),
+		X: rng.IntN(spanWidth),
+		Y: rng.IntN(spanHeight),
 	}
 	return startingPoint
 } 
\ No newline at end of file
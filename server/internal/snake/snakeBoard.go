@@ -1,10 +1,18 @@
 package snake
 
 import (
+	"cmp"
+	"fmt"
+	"game-server/internal/events"
+	"game-server/internal/store"
 	"math/rand/v2"
+	"slices"
 	"sync"
+	"time"
 )
 
+const DeathReasonNeverJoined = "Never joined"
+
 type Food struct {
 	Position Point `json:"position"`
 	Value    int   `json:"value"`
@@ -14,19 +22,19 @@ type Obstacle struct {
 	Object []Point `json:"object"`
 }
 
-func CreateNewRandomObstacle(width, height int) Obstacle {
+func CreateNewRandomObstacle(rng *rand.Rand, width, height int) Obstacle {
 	startingPoint := Point{
-		X: rand.IntN(width),
-		Y: rand.IntN(height),
+		X: rng.IntN(width),
+		Y: rng.IntN(height),
 	}
 
-	length := 3 + rand.IntN(4)
+	length := 3 + rng.IntN(4)
 
 	object := make([]Point, 0, length)
 	object = append(object, startingPoint)
 
 	for i := 1; i < length; {
-		dir := rand.IntN(4)
+		dir := rng.IntN(4)
 		newPoint := startingPoint
 		switch dir {
 		case 0:
@@ -59,6 +67,20 @@ type SnakeBoard struct {
 	numberOfFoodRange int
 	obstacleCount    int
 	Obstacles        []Obstacle
+	// Tick is the number of simulation steps run so far
+	Tick             int64
+	config           Config
+	rules            Rules
+	lastFrame        *boardFrame
+	pendingEvents    []events.Event
+	// Everything random on the board comes from rng, so a board built from
+	// the same seed and fed the same joins and inputs plays out the same
+	seed             uint64
+	rng              *rand.Rand
+	spawns           map[string]Point
+	initialFoods     []Food
+	joins            []RecordedJoin
+	inputs           []RecordedInput
 	mu               sync.RWMutex
 }
 
@@ -70,12 +92,19 @@ type SnakeBoardPlayerInformation struct {
 	Obstacles   []Obstacle `json:"obstacles"`
 }
 
-func NewSnakeBoard() *SnakeBoard {
+// NewSnakeBoard lays out the board for a match from seed. The spawn points of
+// playerIds are drawn up front, so joining late doesn't shift the food.
+func NewSnakeBoard(cfg Config, rules Rules, seed uint64, playerIds []string) *SnakeBoard {
 	snakeControllers := make(map[string]*SnakeController)
 	height := 40
 	width := 60
 
-	obsCount, obstacles := createObstacles(width, height)
+	rng := rand.New(rand.NewPCG(seed, seed))
+	obsCount, obstacles := createObstacles(rng, width, height)
+	spawns := make(map[string]Point, len(playerIds))
+	for _, playerId := range playerIds {
+		spawns[playerId] = newRandomSnakeHead(rng)
+	}
 	snakeBoard := &SnakeBoard{
 		SnakeControllers:  snakeControllers,
 		Foods:             make([]Food, 0),
@@ -85,40 +114,55 @@ func NewSnakeBoard() *SnakeBoard {
 		minimumFood:       4,
 		numberOfFoodRange: 3,
 		obstacleCount:     obsCount,
+		config:            cfg,
+		rules:             rules,
+		seed:              seed,
+		rng:               rng,
+		spawns:            spawns,
 	}
-	snakeBoard.GenerateFood()
+	snakeBoard.generateFood()
+	snakeBoard.initialFoods = slices.Clone(snakeBoard.Foods)
 	return snakeBoard
 }
 
-func (sb *SnakeBoard) AddPlayer(playerId string) {
+func (sb *SnakeBoard) AddPlayer(identity SnakeIdentity) {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 
-	if _, exists := sb.SnakeControllers[playerId]; !exists {
-		sb.SnakeControllers[playerId] = NewSnakeController(NewSnake())
+	sc, exists := sb.SnakeControllers[identity.PlayerId]
+	if !exists {
+		spawn, ok := sb.spawns[identity.PlayerId]
+		if !ok {
+			spawn = newRandomSnakeHead(sb.rng)
+		}
+		sb.SnakeControllers[identity.PlayerId] = NewSnakeController(NewSnake(identity, spawn))
+		// joins happen between steps, the snake first moves in the next one
+		sb.joins = append(sb.joins, RecordedJoin{Tick: sb.Tick, SnakeIdentity: identity})
+		return
 	}
+	// a reconnecting client numbers its moves from the start again
+	sc.ResetInputs()
 }
 
-func (sb *SnakeBoard) GenerateFood() {
-	sb.mu.Lock()
-	defer sb.mu.Unlock()
-
+func (sb *SnakeBoard) generateFood() {
 	snakes := make([]Snake, 0)
 	for _, sc := range sb.SnakeControllers {
-		snakes = append(snakes, *sc.Snake)
+		if !sc.Snake.Removed {
+			snakes = append(snakes, *sc.Snake)
+		}
 	}
 	
-	numberOfFood := sb.minimumFood + rand.IntN(sb.numberOfFoodRange)
+	numberOfFood := sb.minimumFood + sb.rng.IntN(sb.numberOfFoodRange)
 	for len(sb.Foods) < numberOfFood {
-		x := rand.IntN(sb.Width)
-		y := rand.IntN(sb.Height)
+		x := sb.rng.IntN(sb.Width)
+		y := sb.rng.IntN(sb.Height)
 
 		newFood := Food{
 			Position: Point{
 				X: x,
 				Y: y,
 			},
-			Value: 1 + rand.IntN(5),
+			Value: 1 + sb.rng.IntN(5),
 		}
 		if sb.isOccupied(newFood.Position, snakes, sb.Obstacles) {
 			continue
@@ -150,72 +194,289 @@ func (sb *SnakeBoard) isOccupied(p Point, snakes []Snake, obstacles []Obstacle)
 	return false
 }
 
-func (sb *SnakeBoard) ExecutePlayerMovement(playerId string, direction Direction) {
+func (sb *SnakeBoard) ExecutePlayerMovement(playerId string, direction Direction, seq int64) error {
 	sb.mu.RLock()
 	sc, ok := sb.SnakeControllers[playerId]
 	sb.mu.RUnlock()
 
-	if ok {
-		sc.KeyboardController(direction)
+	if !ok {
+		return fmt.Errorf("player %s has no snake", playerId)
+	}
+	return sc.KeyboardController(direction, seq)
+}
+
+// InputAcks returns the last input sequence number handled for every player
+func (sb *SnakeBoard) InputAcks() map[string]int64 {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	acks := make(map[string]int64, len(sb.SnakeControllers))
+	for playerId, sc := range sb.SnakeControllers {
+		acks[playerId] = sc.InputAck()
 	}
+	return acks
 }
 
-func (sb *SnakeBoard) RunSnake(playerId string) (bool, string) {
+// Step runs one simulation step and returns its number. Queued inputs are
+// applied, then snakes move and collide, corpses fade, then food spawns. The
+// board stays locked for the whole step, so a player joins between two steps.
+func (sb *SnakeBoard) Step(playerIds []string) int64 {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 
-	sc, ok := sb.SnakeControllers[playerId]
-	if !ok {
-		return false, "player not found"
+	sb.Tick++
+	if sb.Tick%MOVE_INTERVAL_TICKS == 0 {
+		sb.applyInputs()
+		sb.moveSnakes(playerIds)
+	}
+	sb.decayCorpses()
+	if sb.Tick%FOOD_INTERVAL_TICKS == 0 {
+		sb.generateFood()
 	}
+	return sb.Tick
+}
 
-	isCol, msg := sc.RunSnake(sb)
-	return isCol, msg
+// applyInputs hands every snake its next queued input and records the turns
+func (sb *SnakeBoard) applyInputs() {
+	for playerId, sc := range sb.SnakeControllers {
+		if direction, ok := sc.ApplyInput(); ok {
+			sb.inputs = append(sb.inputs, RecordedInput{Tick: sb.Tick, PlayerId: playerId, Direction: direction})
+		}
+	}
 }
 
-func (sb *SnakeBoard) GetSnakeBoard(playerId string) *SnakeBoardPlayerInformation {
+// decayCorpses counts down the corpses left by DeathModeCorpse and clears
+// the ones that have faded
+func (sb *SnakeBoard) decayCorpses() {
+	for _, sc := range sb.SnakeControllers {
+		snake := sc.Snake
+		if snake.IsAlive || snake.Removed {
+			continue
+		}
+		snake.CorpseTicks--
+		if snake.CorpseTicks <= 0 {
+			snake.remove()
+		}
+	}
+}
+
+// DrainEvents returns and clears the events raised since the last call
+func (sb *SnakeBoard) DrainEvents() []events.Event {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	pending := sb.pendingEvents
+	sb.pendingEvents = nil
+	return pending
+}
+
+// AliveEvents reports how long each living snake has survived
+func (sb *SnakeBoard) AliveEvents() []events.Event {
 	sb.mu.RLock()
 	defer sb.mu.RUnlock()
 
-	snakeController, ok := sb.SnakeControllers[playerId]
-	if !ok {
-		return &SnakeBoardPlayerInformation{
-			PlayerId:    playerId,
-			Foods:       sb.Foods,
-			Obstacles:   sb.Obstacles,
-			OtherSnakes: make([]Snake, 0),
+	alive := make([]events.Event, 0, len(sb.SnakeControllers))
+	for playerId, sc := range sb.SnakeControllers {
+		if sc.Snake.IsAlive {
+			alive = append(alive, events.Event{
+				Type:     events.PlayerAlive,
+				PlayerId: playerId,
+				Value:    int(time.Since(sc.Snake.StartingTime).Seconds()),
+			})
 		}
 	}
+	return alive
+}
+
+func (sb *SnakeBoard) IsPlayerAlive(playerId string) bool {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	sc, ok := sb.SnakeControllers[playerId]
+	return ok && sc.Snake.IsAlive
+}
+
+// LivePlayers reports the score of every player of the match, players who
+// haven't joined yet are listed with no score
+func (sb *SnakeBoard) LivePlayers(playerIds []string, playerName func(playerId string) string) []LivePlayer {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	players := make([]LivePlayer, 0, len(playerIds))
+	for _, playerId := range playerIds {
+		player := LivePlayer{PlayerId: playerId}
+		if sc, ok := sb.SnakeControllers[playerId]; ok {
+			player.Name = sc.Snake.Name
+			player.Score = sc.Snake.Score.Value
+			player.Alive = sc.Snake.IsAlive
+		} else {
+			player.Name = playerName(playerId)
+		}
+		players = append(players, player)
+	}
+	return players
+}
+
+func (sb *SnakeBoard) GetSnakeBoard(playerId string) *SnakeBoardPlayerInformation {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
 
-	playerSnake := snakeController.Snake
 	foods := sb.Foods
 	obstacles := sb.Obstacles
 	otherSnakes := make([]Snake, 0)
 	
+	// someone without a snake, like a spectator, sees every snake as another's
 	for pId, sc := range sb.SnakeControllers {
-		if pId != playerId {
+		if pId != playerId && !sc.Snake.Removed {
 			otherSnakes = append(otherSnakes, *sc.Snake)
 		}
 	}
 
-	return &SnakeBoardPlayerInformation{
+	info := &SnakeBoardPlayerInformation{
 		PlayerId:    playerId,
-		PlayerSnake: *playerSnake,
 		Foods:       foods,
 		Obstacles:   obstacles,
 		OtherSnakes: otherSnakes,
 	}
+	if snakeController, ok := sb.SnakeControllers[playerId]; ok {
+		info.PlayerSnake = *snakeController.Snake
+	}
+	return info
+}
+
+// GameOver checks the match rules after a tick and returns why the match is
+// over, or an empty string while it goes on
+func (sb *SnakeBoard) GameOver(playerIds []string) string {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	if limit := sb.rules.timeLimitTicks(); limit > 0 && sb.Tick >= limit {
+		return GameOverTimeLimit
+	}
+
+	joined, alive := 0, 0
+	for _, playerId := range playerIds {
+		sc, ok := sb.SnakeControllers[playerId]
+		if !ok {
+			continue
+		}
+		joined++
+		if sc.Snake.IsAlive {
+			alive++
+		}
+		if sb.rules.Victory == VictoryScoreTarget && sc.Snake.Score.Value >= sb.rules.ScoreTarget {
+			return GameOverScoreTarget
+		}
+	}
+
+	switch {
+	case joined == 0:
+		return ""
+	case alive == 0:
+		return GameOverAllDead
+	case sb.rules.Victory == VictoryLastAlive && len(playerIds) > 1 && alive <= 1 &&
+		(joined == len(playerIds) || sb.Tick >= JOIN_GRACE_TICKS):
+		return GameOverLastAlive
+	}
+	return ""
+}
+
+// Results ranks every player of the match. Under VictoryLastAlive snakes that
+// survived longer rank first, otherwise the highest score wins with surviving
+// snakes ahead of dead ones on equal score. Players that never connected are
+// listed last without a placement.
+func (sb *SnakeBoard) Results(playerIds []string) []store.PlayerResult {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	type standing struct {
+		result   store.PlayerResult
+		alive    bool
+		joined   bool
+		diedTick int64
+	}
+	kills := make(map[string]int)
+	for _, sc := range sb.SnakeControllers {
+		if sc.Snake.Stats.Killer != "" {
+			kills[sc.Snake.Stats.Killer]++
+		}
+	}
+
+	now := time.Now()
+	standings := make([]standing, 0, len(playerIds))
+	for _, playerId := range playerIds {
+		st := standing{result: store.PlayerResult{PlayerId: playerId, DeathReason: DeathReasonNeverJoined}}
+		if sc, ok := sb.SnakeControllers[playerId]; ok {
+			snake := sc.Snake
+			st.joined = true
+			st.alive = snake.IsAlive
+			st.diedTick = snake.Stats.DiedTick
+			st.result.FinalScore = snake.Score.Value
+			st.result.DeathReason = snake.DeathReason
+			st.result.MaxLength = snake.Stats.MaxLength
+			st.result.FoodEaten = snake.Stats.FoodEaten
+			st.result.Kills = kills[playerId]
+			st.result.TimeAlive = now.Sub(snake.StartingTime)
+			if !snake.IsAlive {
+				st.result.TimeAlive = snake.Stats.DiedAt.Sub(snake.StartingTime)
+			}
+		}
+		standings = append(standings, st)
+	}
+
+	compare := func(a, b standing) int {
+		if a.joined != b.joined {
+			return boolOrder(b.joined, a.joined)
+		}
+		if sb.rules.Victory == VictoryLastAlive {
+			if a.alive != b.alive {
+				return boolOrder(b.alive, a.alive)
+			}
+			if c := cmp.Compare(b.diedTick, a.diedTick); c != 0 {
+				return c
+			}
+		}
+		if c := cmp.Compare(b.result.FinalScore, a.result.FinalScore); c != 0 {
+			return c
+		}
+		return boolOrder(b.alive, a.alive)
+	}
+	slices.SortStableFunc(standings, compare)
+
+	results := make([]store.PlayerResult, 0, len(standings))
+	for i, st := range standings {
+		if st.joined {
+			placement := i + 1
+			if i > 0 && compare(standings[i-1], st) == 0 {
+				placement = *results[i-1].Placement
+			}
+			st.result.Placement = &placement
+		}
+		results = append(results, st.result)
+	}
+	return results
+}
+
+func boolOrder(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
 }
 
-func createObstacles(w, h int) (int, []Obstacle) {
+func createObstacles(rng *rand.Rand, w, h int) (int, []Obstacle) {
 	minimumObstacles := 2
 	numberOfObstacleRange := 3
 
-	obstacleCount := minimumObstacles + rand.IntN(numberOfObstacleRange)
+	obstacleCount := minimumObstacles + rng.IntN(numberOfObstacleRange)
 
 	obstacles := make([]Obstacle, 0, obstacleCount)
 	for i := 0; i < obstacleCount; i++ {
-		obstacles = append(obstacles, CreateNewRandomObstacle(w, h))
+		obstacles = append(obstacles, CreateNewRandomObstacle(rng, w, h))
 	}
 
 	return obstacleCount, obstacles
@@ -1,8 +1,13 @@
 package snake
 
 import (
-	"log"
+	"fmt"
+	"game-server/internal/events"
+	"game-server/internal/store"
+	"math/rand/v2"
+	"slices"
 	"sync"
+	"time"
 )
 
 const (
@@ -10,11 +15,23 @@ const (
 	BOARD_WIDTH             = 60
 	BOARD_HEIGHT            = 40
 	CELL_SIZE               = 10
+
+	// Every match runs on one clock, the other intervals are counted in ticks
+	TICK_INTERVAL       = 100 * time.Millisecond
+	MOVE_INTERVAL_TICKS = 5
+	FOOD_INTERVAL_TICKS = 10
 )
 
 type SnakeService struct {
 	SnakeBoards  map[string]*SnakeBoard
 	MatchPlayers map[string][]string
+	MatchGames   map[string]string
+	MatchStarts  map[string]time.Time
+	matchStore   store.MatchStore
+	replayStore  store.ReplayStore
+	bus          *events.Bus
+	config       Config
+	playerName   func(playerId string) string
 	mu           sync.RWMutex
 }
 
@@ -24,10 +41,17 @@ type SnakeGameMetaDataResponse struct {
 	CellSize    int `json:"cellSize"`
 }
 
-func NewSnakeService() *SnakeService {
+func NewSnakeService(matchStore store.MatchStore, replayStore store.ReplayStore, bus *events.Bus, cfg Config, playerName func(playerId string) string) *SnakeService {
 	return &SnakeService{
 		SnakeBoards:  make(map[string]*SnakeBoard),
 		MatchPlayers: make(map[string][]string),
+		MatchGames:   make(map[string]string),
+		MatchStarts:  make(map[string]time.Time),
+		matchStore:   matchStore,
+		replayStore:  replayStore,
+		bus:          bus,
+		config:       cfg,
+		playerName:   playerName,
 	}
 }
 
@@ -39,44 +63,62 @@ func (ss *SnakeService) SnakeGameMetaData() *SnakeGameMetaDataResponse {
 	}
 }
 
-func (ss *SnakeService) StartGame(matchId string, playerIds []string) {
+func (ss *SnakeService) StartGame(matchId, gameId string, playerIds []string) {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
 
 	if _, ok := ss.SnakeBoards[matchId]; ok {
 		return
 	}
-	ss.SnakeBoards[matchId] = NewSnakeBoard()
+	ss.SnakeBoards[matchId] = NewSnakeBoard(ss.config, RulesFor(gameId), rand.Uint64(), playerIds)
 	ss.MatchPlayers[matchId] = playerIds
+	ss.MatchGames[matchId] = gameId
+	ss.MatchStarts[matchId] = time.Now()
 }
 
 func (ss *SnakeService) AddPlayer(matchId, playerId string) {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
 
-	if sb, ok := ss.SnakeBoards[matchId]; ok {
-		sb.AddPlayer(playerId)
+	sb, ok := ss.SnakeBoards[matchId]
+	if !ok {
+		return
 	}
+
+	identity := SnakeIdentity{PlayerId: playerId, Name: ss.displayName(playerId)}
+	seat := max(slices.Index(ss.MatchPlayers[matchId], playerId), 0)
+	identity.Color = snakeColors[seat%len(snakeColors)]
+	sb.AddPlayer(identity)
 }
 
-func (ss *SnakeService) ExecuteMovement(matchId, playerId string, direction Direction) {
+// displayName is the player's username, or the id of a player without one
+func (ss *SnakeService) displayName(playerId string) string {
+	if name := ss.playerName(playerId); name != "" {
+		return name
+	}
+	return playerId
+}
+
+func (ss *SnakeService) ExecuteMovement(matchId, playerId string, direction Direction, seq int64) error {
 	ss.mu.RLock()
 	snakeBoard, ok := ss.SnakeBoards[matchId]
 	ss.mu.RUnlock()
 
-	if ok {
-		snakeBoard.ExecutePlayerMovement(playerId, direction)
+	if !ok {
+		return fmt.Errorf("match %s is not running", matchId)
 	}
+	return snakeBoard.ExecutePlayerMovement(playerId, direction, seq)
 }
 
-func (ss *SnakeService) GenerateFood(matchId string) {
+func (ss *SnakeService) InputAcks(matchId string) map[string]int64 {
 	ss.mu.RLock()
 	sb, ok := ss.SnakeBoards[matchId]
 	ss.mu.RUnlock()
 
-	if ok {
-		sb.GenerateFood()
+	if !ok {
+		return map[string]int64{}
 	}
+	return sb.InputAcks()
 }
 
 func (ss *SnakeService) GetBoardStats(matchId, playerId string) *SnakeBoardPlayerInformation {
@@ -90,31 +132,105 @@ func (ss *SnakeService) GetBoardStats(matchId, playerId string) *SnakeBoardPlaye
 	return &SnakeBoardPlayerInformation{}
 }
 
-func (ss *SnakeService) RunAllSnake(matchId string) {
+// Tick runs one simulation step of the match, see SnakeBoard.Step, and
+// sends out what happened in it. It returns the tick number, or 0 when the
+// match is not running.
+func (ss *SnakeService) Tick(matchId string) int64 {
 	ss.mu.RLock()
 	sb, ok := ss.SnakeBoards[matchId]
 	players := ss.MatchPlayers[matchId]
 	ss.mu.RUnlock()
 
 	if !ok {
-		log.Println("Match id not found")
-		return
+		return 0
+	}
+
+	tick := sb.Step(players)
+	pending := sb.DrainEvents()
+	for _, e := range pending {
+		if e.Type == events.PlayerDied {
+			broadcastPlayerDied(matchId, e)
+		}
+	}
+	ss.publish(matchId, pending...)
+
+	if tick%FOOD_INTERVAL_TICKS == 0 {
+		ss.PublishAlive(matchId)
+	}
+	return tick
+}
+
+// PublishAlive reports the survival time of every living snake in the match
+func (ss *SnakeService) PublishAlive(matchId string) {
+	ss.mu.RLock()
+	sb, ok := ss.SnakeBoards[matchId]
+	ss.mu.RUnlock()
+
+	if ok {
+		ss.publish(matchId, sb.AliveEvents()...)
+	}
+}
+
+// publish stamps engine events with their match and game before sending them
+func (ss *SnakeService) publish(matchId string, evts ...events.Event) {
+	ss.mu.RLock()
+	gameId := ss.MatchGames[matchId]
+	ss.mu.RUnlock()
+
+	for _, e := range evts {
+		e.MatchId = matchId
+		e.GameId = gameId
+		ss.bus.Publish(e)
+	}
+}
+
+func (ss *SnakeService) IsPlayerAlive(matchId, playerId string) bool {
+	ss.mu.RLock()
+	sb, ok := ss.SnakeBoards[matchId]
+	ss.mu.RUnlock()
+
+	if !ok {
+		return false
 	}
+	return sb.IsPlayerAlive(playerId)
+}
 
-	for _, playerId := range players {
-		sb.RunSnake(playerId)
+// StateDelta returns what changed on the board since the previous call
+func (ss *SnakeService) StateDelta(matchId string) *BoardDelta {
+	ss.mu.RLock()
+	sb, ok := ss.SnakeBoards[matchId]
+	ss.mu.RUnlock()
+
+	if !ok {
+		return nil
 	}
+	return sb.Delta()
 }
 
-func (ss *SnakeService) RunSnake(matchId, playerId string) (bool, string) {
+// GameOver returns why the match is over, or an empty string while it goes on
+func (ss *SnakeService) GameOver(matchId string) string {
 	ss.mu.RLock()
 	sb, ok := ss.SnakeBoards[matchId]
+	players := ss.MatchPlayers[matchId]
 	ss.mu.RUnlock()
 
 	if !ok {
-		return false, "snake board not found"
+		return ""
 	}
-	return sb.RunSnake(playerId)
+	return sb.GameOver(players)
+}
+
+// MatchResults ranks the players of a running match
+func (ss *SnakeService) MatchResults(matchId string) []store.PlayerResult {
+	ss.mu.RLock()
+	sb, ok := ss.SnakeBoards[matchId]
+	players := ss.MatchPlayers[matchId]
+	ss.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	return sb.Results(players)
 }
 
 func (ss *SnakeService) EndGame(matchId string) {
@@ -123,4 +239,54 @@ func (ss *SnakeService) EndGame(matchId string) {
 
 	delete(ss.SnakeBoards, matchId)
 	delete(ss.MatchPlayers, matchId)
+	delete(ss.MatchGames, matchId)
+	delete(ss.MatchStarts, matchId)
+}
+
+// LiveMatch is a running match as listed in the live directory
+type LiveMatch struct {
+	MatchId        string       `json:"matchId"`
+	GameId         string       `json:"gameId"`
+	Players        []LivePlayer `json:"players"`
+	StartedAt      time.Time    `json:"startedAt"`
+	ElapsedSeconds int64        `json:"elapsedSeconds"`
+	Spectators     int          `json:"spectators"`
+}
+
+type LivePlayer struct {
+	PlayerId string `json:"playerId"`
+	Name     string `json:"name"`
+	Score    int    `json:"score"`
+	Alive    bool   `json:"alive"`
+}
+
+// LiveMatches lists the running matches, newest first
+func (ss *SnakeService) LiveMatches() []LiveMatch {
+	ss.mu.RLock()
+	matches := make([]LiveMatch, 0, len(ss.SnakeBoards))
+	boards := make([]*SnakeBoard, 0, len(ss.SnakeBoards))
+	playerIds := make([][]string, 0, len(ss.SnakeBoards))
+	for matchId, sb := range ss.SnakeBoards {
+		matches = append(matches, LiveMatch{
+			MatchId:   matchId,
+			GameId:    ss.MatchGames[matchId],
+			StartedAt: ss.MatchStarts[matchId],
+		})
+		boards = append(boards, sb)
+		playerIds = append(playerIds, ss.MatchPlayers[matchId])
+	}
+	ss.mu.RUnlock()
+
+	// the boards lock themselves, they're read outside the service lock
+	now := time.Now()
+	for i := range matches {
+		m := &matches[i]
+		m.Players = boards[i].LivePlayers(playerIds[i], ss.displayName)
+		m.ElapsedSeconds = int64(now.Sub(m.StartedAt).Seconds())
+		m.Spectators = spectatorCount(m.MatchId)
+	}
+	slices.SortFunc(matches, func(a, b LiveMatch) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return matches
 }
\ No newline at end of file
@@ -0,0 +1,87 @@
+package snake
+
+import (
+	"game-server/internal/events"
+	"game-server/internal/store"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testConfig keeps test connections from timing out on their own
+var testConfig = Config{
+	DeathMode:      DeathModeCorpse,
+	CorpseTicks:    10,
+	PingInterval:   time.Minute,
+	PongTimeout:    2 * time.Minute,
+	WriteTimeout:   2 * time.Second,
+	MaxMessageSize: DefaultMaxMessageSize,
+	ReconnectGrace: time.Second,
+	SpectatorChat:  true,
+}
+
+func newTestSnakeService() *SnakeService {
+	names := map[string]string{"a": "alice"}
+	s := store.NewMemoryStore()
+	return NewSnakeService(s, s, events.NewBus(), testConfig,
+		func(playerId string) string { return names[playerId] })
+}
+
+func TestAddPlayerIdentity(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.StartGame("m1", "snake", []string{"a", "b"})
+	ss.AddPlayer("m1", "b")
+	ss.AddPlayer("m1", "a")
+
+	view := ss.GetBoardStats("m1", "a")
+	if got := view.PlayerSnake.SnakeIdentity; got != (SnakeIdentity{PlayerId: "a", Name: "alice", Color: snakeColors[0]}) {
+		t.Errorf("own snake = %+v", got)
+	}
+	if len(view.OtherSnakes) != 1 {
+		t.Fatalf("a sees %d other snakes, want 1", len(view.OtherSnakes))
+	}
+	// players without a known name are shown by id, colors follow the seat
+	if got := view.OtherSnakes[0].SnakeIdentity; got != (SnakeIdentity{PlayerId: "b", Name: "b", Color: snakeColors[1]}) {
+		t.Errorf("other snake = %+v", got)
+	}
+}
+
+func TestSpectatorSeesEverySnake(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.StartGame("m1", "snake", []string{"a", "b"})
+	ss.AddPlayer("m1", "a")
+	ss.AddPlayer("m1", "b")
+
+	view := ss.GetBoardStats("m1", "watcher")
+	if view.PlayerSnake.PlayerId != "" || len(view.OtherSnakes) != 2 {
+		t.Fatalf("spectator sees own snake %+v and %d others, want none and 2", view.PlayerSnake, len(view.OtherSnakes))
+	}
+}
+
+func TestLiveMatches(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.StartGame("m-live-old", "snake", []string{"a", "b"})
+	ss.StartGame("m-live-new", "snake", []string{"c"})
+	ss.MatchStarts["m-live-old"] = time.Now().Add(-time.Minute)
+	ss.AddPlayer("m-live-old", "a")
+
+	startSpectatorFeed("m-live-old", time.Minute)
+	t.Cleanup(func() { endSpectatorFeed("m-live-old") })
+	spectator, _ := servePlayerConn(t, SUBPROTOCOL_JSON, false)
+	registerSpectator("m-live-old", "x", spectator)
+	t.Cleanup(func() { unregisterSpectator("m-live-old", "x", spectator) })
+
+	live := ss.LiveMatches()
+	if len(live) != 2 || live[0].MatchId != "m-live-new" || live[1].MatchId != "m-live-old" {
+		t.Fatalf("LiveMatches = %+v, want the newest match first", live)
+	}
+	old := live[1]
+	if old.ElapsedSeconds < 59 || old.Spectators != 1 || old.GameId != "snake" {
+		t.Errorf("old match = %+v", old)
+	}
+	// b hasn't joined yet, so there is no snake to score
+	want := []LivePlayer{{PlayerId: "a", Name: "alice", Alive: true}, {PlayerId: "b", Name: "b"}}
+	if !slices.Equal(old.Players, want) {
+		t.Errorf("players = %+v, want %+v", old.Players, want)
+	}
+}
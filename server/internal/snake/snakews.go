@@ -1,42 +1,104 @@
 package snake
 
 import (
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+	"game-server/internal/events"
+	"game-server/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+// HANDSHAKE_TIMEOUT is how long a new connection has to send its hello
+const HANDSHAKE_TIMEOUT = 5 * time.Second
+
+// Close codes for connections turned away before the handshake
+const (
+	CloseForbidden     = 4003
+	CloseMatchNotFound = 4004
+)
+
+// Roles of a connection. Players own a snake, spectators only watch and
+// viewers watch the replay of a finished match.
+const (
+	RolePlayer    = "player"
+	RoleSpectator = "spectator"
+	RoleViewer    = "viewer"
+)
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
+	// the client's order of preference wins
+	Subprotocols: []string{SUBPROTOCOL_MSGPACK, SUBPROTOCOL_JSON},
+}
+
+// handshake waits for the client's hello and answers it with a welcome.
+// Clients speaking another protocol version are turned away, and so are
+// clients taking over a player's session without its resume token.
+func (pc *playerConn) handshake(playerId, matchId string) error {
+	pc.conn.SetReadDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+	defer pc.keepAlive()
+
+	_, message, err := pc.conn.ReadMessage()
+	if err != nil {
+		return fmt.Errorf("waiting for hello: %v", err)
+	}
+
+	var hello Envelope[Hello]
+	code, reason := "", ""
+	switch err := pc.codec.Unmarshal(message, &hello); {
+	case err != nil:
+		code, reason = ErrorInvalidMessage, err.Error()
+	case hello.Type != MessageHello:
+		code, reason = ErrorHandshakeRequired, fmt.Sprintf("expected %s, got %q", MessageHello, hello.Type)
+	case hello.Version != PROTOCOL_VERSION:
+		code, reason = ErrorUnsupportedVersion, fmt.Sprintf("server speaks version %d, client %d", PROTOCOL_VERSION, hello.Version)
+	}
+	if code != "" {
+		pc.sendError(hello.Seq, code, reason)
+		pc.closeWith(websocket.CloseProtocolError, code)
+		return fmt.Errorf("%s: %s", code, reason)
+	}
+
+	welcome := Welcome{
+		ProtocolVersion: PROTOCOL_VERSION,
+		Encoding:        pc.codec.Name(),
+		PlayerId:        playerId,
+		MatchId:         matchId,
+		Role:            pc.role,
+	}
+	// spectators have no snake to come back to
+	if pc.role == RolePlayer {
+		welcome.ResumeToken, welcome.Resumed, err = claimSession(matchId, playerId, hello.Payload.ResumeToken, pc)
+		if err != nil {
+			pc.sendError(hello.Seq, ErrorInvalidResumeToken, err.Error())
+			pc.closeWith(websocket.ClosePolicyViolation, ErrorInvalidResumeToken)
+			return err
+		}
+	}
+	return pc.send(MessageWelcome, hello.Seq, welcome)
 }
 
 var (
-	matchConnections = make(map[string]map[string]*websocket.Conn)
+	matchConnections = make(map[string]map[string]*playerConn)
+	matchSpectators  = make(map[string]map[string]*playerConn)
+	// players that get a full snapshot instead of the next delta
+	snapshotRequests = make(map[string]map[string]bool)
 	matchConnMutex   sync.RWMutex
 	activeMatches    = make(map[string]bool)
 	activeMatchLock  sync.RWMutex
-	snakeService     *SnakeService
-	serviceOnce      sync.Once
 )
 
-// GetSnakeService returns singleton instance
-func GetSnakeService() *SnakeService {
-	serviceOnce.Do(func() {
-		snakeService = NewSnakeService()
-	})
-	return snakeService
-}
-
-func WsHandler(c *gin.Context) {
+func (ss *SnakeService) WsHandler(c *gin.Context) {
 	playerId := c.Query("playerId")
 	matchId := c.Query("matchId")
 
@@ -50,142 +112,354 @@ func WsHandler(c *gin.Context) {
 		log.Println("Upgrading error:", err)
 		return
 	}
-	defer conn.Close()
-
-	registerConnection(matchId, playerId, conn)
-	defer unregisterConnection(matchId, playerId)
-	log.Printf("Player %s connected to match %s", playerId, matchId)
+	pc := newPlayerConn(conn, codecFor(conn.Subprotocol()), ss.config)
+	defer pc.closeWith(websocket.CloseNormalClosure, "")
+	pc.role = RolePlayer
 
-	db, err := sql.Open("sqlite3", "./matches.db")
-	if err != nil {
-		log.Printf("DB open error: %v", err)
+	match, ok := ss.loadOpenMatch(pc, matchId)
+	if !ok {
+		return
+	}
+	playerIds := match.Players
+	if !slices.Contains(playerIds, playerId) {
+		log.Printf("Player %s is not in match %s", playerId, matchId)
+		pc.closeWith(CloseForbidden, "not a player of this match")
 		return
 	}
-	defer db.Close()
 
-	playerIds, err := getPlayersByMatchId(db, matchId)
-	if err != nil {
-		log.Printf("Failed to load playerIds for match %s: %v", matchId, err)
+	if err := pc.handshake(playerId, matchId); err != nil {
+		log.Printf("Handshake with %s failed: %v", playerId, err)
 		return
 	}
-	log.Printf("Players in match %s: %v", matchId, playerIds)
 
-	service := GetSnakeService()
-	
+	registerConnection(matchId, playerId, pc)
+	defer unregisterConnection(matchId, playerId, pc)
+	log.Printf("Player %s connected to match %s using %s", playerId, matchId, pc.codec.Name())
+
 	// Initialize the game first, then add the player
-	startMatchLoopOnce(matchId, playerIds)
-	service.AddPlayer(matchId, playerId)
+	ss.startMatchLoopOnce(matchId, match.GameId, playerIds)
+	ss.AddPlayer(matchId, playerId)
 
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
-			log.Printf("Error reading message from player %s: %v", playerId, err)
+			pc.readFailed(err)
+			log.Printf("Player %s disconnected from match %s (%s): %v", playerId, matchId, pc.disconnectReason(), err)
 			break
 		}
-		handlePlayerInput(matchId, playerId, message)
+		pc.keepAlive()
+		ss.handlePlayerInput(matchId, playerId, pc, message)
+	}
+
+	// Leaving a running multiplayer match with a living snake is an abandon,
+	// unless the player is back within the grace window. The snake keeps
+	// moving meanwhile, so dropping the connection can't dodge a collision.
+	if len(playerIds) > 1 {
+		time.AfterFunc(ss.config.ReconnectGrace, func() {
+			if isLatestConnection(matchId, playerId, pc) && isMatchActive(matchId) && ss.IsPlayerAlive(matchId, playerId) {
+				ss.publish(matchId, events.Event{Type: events.PlayerAbandoned, PlayerId: playerId, Cause: pc.disconnectReason()})
+			}
+		})
+	}
+}
+
+// loadOpenMatch loads a match that hasn't ended. Browsers can't see the HTTP
+// status of a failed upgrade, so joins are turned away with close codes.
+func (ss *SnakeService) loadOpenMatch(pc *playerConn, matchId string) (*store.Match, bool) {
+	match, err := ss.matchStore.LoadMatch(matchId)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		pc.closeWith(CloseMatchNotFound, "match not found")
+		return nil, false
+	case err != nil:
+		log.Printf("Failed to load match %s: %v", matchId, err)
+		pc.closeWith(websocket.CloseInternalServerErr, "failed to load match")
+		return nil, false
+	case !match.EndedAt.IsZero():
+		pc.closeWith(CloseMatchNotFound, "match is over")
+		return nil, false
 	}
+	return match, true
 }
 
-func registerConnection(matchId, playerId string, conn *websocket.Conn) {
+func isMatchActive(matchId string) bool {
+	activeMatchLock.RLock()
+	defer activeMatchLock.RUnlock()
+
+	return activeMatches[matchId]
+}
+
+// registerConnection makes pc the player's connection, the newest one wins
+// and an older one still open is closed. Every connection starts with a
+// full snapshot.
+func registerConnection(matchId, playerId string, pc *playerConn) {
 	matchConnMutex.Lock()
 	defer matchConnMutex.Unlock()
 
 	if matchConnections[matchId] == nil {
-		matchConnections[matchId] = make(map[string]*websocket.Conn)
+		matchConnections[matchId] = make(map[string]*playerConn)
 	}
 
-	matchConnections[matchId][playerId] = conn
+	if old, ok := matchConnections[matchId][playerId]; ok && old != pc {
+		log.Printf("Player %s reconnected to match %s, closing the old connection", playerId, matchId)
+		old.closeWith(CloseSessionReplaced, "replaced by a new connection")
+	}
+	matchConnections[matchId][playerId] = pc
+	requestSnapshotLocked(matchId, playerId)
 }
 
-func unregisterConnection(matchId, playerId string) {
+// requestSnapshot makes the next state message to the player a full snapshot
+func requestSnapshot(matchId, playerId string) {
 	matchConnMutex.Lock()
 	defer matchConnMutex.Unlock()
 
-	if matchConnections[matchId] != nil {
+	requestSnapshotLocked(matchId, playerId)
+}
+
+func requestSnapshotLocked(matchId, playerId string) {
+	if snapshotRequests[matchId] == nil {
+		snapshotRequests[matchId] = make(map[string]bool)
+	}
+	snapshotRequests[matchId][playerId] = true
+}
+
+// takeSnapshotRequest reports and clears a pending snapshot request
+func takeSnapshotRequest(matchId, playerId string) bool {
+	matchConnMutex.Lock()
+	defer matchConnMutex.Unlock()
+
+	requested := snapshotRequests[matchId][playerId]
+	delete(snapshotRequests[matchId], playerId)
+	return requested
+}
+
+// matchAudience copies the players' and spectators' connections of a match
+func matchAudience(matchId string) (players, spectators map[string]*playerConn) {
+	matchConnMutex.RLock()
+	defer matchConnMutex.RUnlock()
+
+	return maps.Clone(matchConnections[matchId]), maps.Clone(matchSpectators[matchId])
+}
+
+// unregisterConnection forgets pc unless a newer connection replaced it
+func unregisterConnection(matchId, playerId string, pc *playerConn) {
+	matchConnMutex.Lock()
+	defer matchConnMutex.Unlock()
+
+	if matchConnections[matchId][playerId] == pc {
 		delete(matchConnections[matchId], playerId)
+		delete(snapshotRequests[matchId], playerId)
 		if len(matchConnections[matchId]) == 0 {
 			delete(matchConnections, matchId)
 		}
 	}
 }
 
-func broadcastChatToMatch(matchId string, chat PlayerChat) {
-	matchConnMutex.RLock()
-	conns := make(map[string]*websocket.Conn)
-	for k, v := range matchConnections[matchId] {
-		conns[k] = v
+// broadcastChatToMatch sends chat to the players right away, and to the
+// spectators too when they may read it
+func (ss *SnakeService) broadcastChatToMatch(matchId string, chat PlayerChat) {
+	players, spectators := matchAudience(matchId)
+	conns := slices.Collect(maps.Values(players))
+	if ss.config.SpectatorChat {
+		conns = slices.AppendSeq(conns, maps.Values(spectators))
 	}
-	matchConnMutex.RUnlock()
+	broadcast(matchId, conns, MessageChat, chat)
+}
 
-	msg, err := json.Marshal(chat)
-	if err != nil {
-		log.Println("Error marshalling chat:", err)
-		return
+// broadcastToMatch sends a message to the players, spectators get it once
+// the board has caught up with it
+func broadcastToMatch(matchId, msgType string, payload any) {
+	players, _ := matchAudience(matchId)
+	broadcast(matchId, slices.Collect(maps.Values(players)), msgType, payload)
+	if feed := spectatorFeedOf(matchId); feed != nil {
+		feed.push(feedEntry{msgType: msgType, payload: payload})
 	}
+}
 
-	for playerId, conn := range conns {
-		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-			log.Printf("Error sending chat to %s: %v", playerId, err)
+// broadcast encodes the message once for every protocol in use
+func broadcast(matchId string, conns []*playerConn, msgType string, payload any) {
+	msg := Envelope[any]{Type: msgType, Version: PROTOCOL_VERSION, Payload: payload}
+	encoded := make(map[string][]byte)
+	for _, pc := range conns {
+		data, ok := encoded[pc.codec.Name()]
+		if !ok {
+			var err error
+			data, err = pc.codec.Marshal(msg)
+			if err != nil {
+				log.Printf("Error marshalling %s: %v", msgType, err)
+				return
+			}
+			encoded[pc.codec.Name()] = data
+		}
+		if err := pc.queue(data); err != nil {
+			log.Printf("Error broadcasting to match %s: %v", matchId, err)
 		}
 	}
 }
 
-func broadcastToMatch(matchId string, message []byte) {
+func sendToPlayer(matchId, playerId, msgType string, payload any) {
 	matchConnMutex.RLock()
-	conns := make([]*websocket.Conn, 0, len(matchConnections[matchId]))
-	for _, conn := range matchConnections[matchId] {
-		conns = append(conns, conn)
-	}
+	pc, ok := matchConnections[matchId][playerId]
 	matchConnMutex.RUnlock()
 
-	for _, conn := range conns {
-		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			log.Printf("Error broadcasting to match %s: %v", matchId, err)
-		}
+	if !ok {
+		return
+	}
+	if err := pc.send(msgType, 0, payload); err != nil {
+		log.Printf("Error sending to %s in match %s: %v", playerId, matchId, err)
+	}
+}
+
+// NotifyAchievement tells a player about an achievement unlocked during their match
+func (ss *SnakeService) NotifyAchievement(e events.Event) {
+	if e.MatchId == "" {
+		return
 	}
+
+	sendToPlayer(e.MatchId, e.PlayerId, MessageAchievement, AchievementNotice{
+		PlayerId:      e.PlayerId,
+		AchievementId: e.Achievement,
+	})
 }
 
-type PlayerMessage struct {
-	Type string `json:"type"`
+// broadcastPlayerDied tells everyone in the match who died, how and to whom
+func broadcastPlayerDied(matchId string, e events.Event) {
+	broadcastToMatch(matchId, MessagePlayerDied, playerDiedNotice(e))
+}
+
+func playerDiedNotice(e events.Event) PlayerDiedNotice {
+	return PlayerDiedNotice{
+		Tick:     e.Tick,
+		PlayerId: e.PlayerId,
+		Cause:    e.Cause,
+		Killer:   e.Killer,
+		Score:    e.Value,
+	}
 }
 
-type PlayerMove struct {
-	Type      string `json:"type"`
-	Direction string `json:"direction"`
+// broadcastGameOver sends the final standings to everyone in the match
+func broadcastGameOver(matchId string, tick int64, reason string, results []store.PlayerResult) {
+	broadcastToMatch(matchId, MessageGameOver, GameOverNotice{
+		Tick:      tick,
+		Reason:    reason,
+		Standings: standingsOf(results),
+	})
 }
 
-type PlayerChat struct {
-	Type    string `json:"type"`
-	From    string `json:"from"`
-	Message string `json:"message"`
+func standingsOf(results []store.PlayerResult) []Standing {
+	standings := make([]Standing, 0, len(results))
+	for _, r := range results {
+		standings = append(standings, Standing{
+			PlayerId:    r.PlayerId,
+			Placement:   r.Placement,
+			Score:       r.FinalScore,
+			DeathReason: r.DeathReason,
+		})
+	}
+	return standings
 }
 
-func handlePlayerInput(matchId, playerId string, input []byte) {
-	var msg PlayerMessage
-	if err := json.Unmarshal(input, &msg); err != nil {
-		log.Printf("Invalid json from %s: %s", playerId, input)
+// closeMatchConnections sends the players home, spectators are closed by the
+// spectator feed once it has caught up
+func closeMatchConnections(matchId string) {
+	players, _ := matchAudience(matchId)
+
+	// game_over is queued ahead of the close
+	for _, pc := range players {
+		pc.closeWith(websocket.CloseNormalClosure, "game over")
+	}
+}
+
+func (ss *SnakeService) handlePlayerInput(matchId, playerId string, pc *playerConn, input []byte) {
+	msg, ok := pc.decodeEnvelope(playerId, input)
+	if !ok {
 		return
 	}
 
+	var err error
 	switch msg.Type {
-	case "move":
-		handleMove(matchId, playerId, input)
-	case "chat":
-		handleChat(matchId, playerId, input)
+	case MessageMove, MessageChat:
+		if pc.role == RoleSpectator {
+			err = &protocolError{code: ErrorForbidden, message: fmt.Sprintf("spectators can't send %s", msg.Type)}
+			break
+		}
+		if msg.Type == MessageChat {
+			err = ss.handleChat(matchId, playerId, pc.codec, input)
+			break
+		}
+
+		err = ss.handleMove(matchId, playerId, pc.codec, input)
+	case MessageResync:
+		// the client lost track of the board and wants a full snapshot
+		requestSnapshot(matchId, playerId)
 	default:
-		log.Printf("Unknown message type from %s: %s", playerId, msg.Type)
+		err = &protocolError{code: ErrorUnknownType, message: fmt.Sprintf("unknown message type %q", msg.Type)}
+	}
+
+	if err != nil {
+		pc.reject(playerId, msg, err)
 	}
 }
 
-func handleMove(matchId, playerId string, input []byte) {
-	var move PlayerMove
-	if err := json.Unmarshal(input, &move); err != nil {
-		log.Printf("Invalid move message from %s: %s", playerId, string(input))
-		return
+// decodeEnvelope reads the envelope of a client message. A message that
+// can't be handled is answered with an error and reported as not ok.
+func (pc *playerConn) decodeEnvelope(from string, input []byte) (Envelope[noPayload], bool) {
+	var msg Envelope[noPayload]
+	if err := pc.codec.Unmarshal(input, &msg); err != nil {
+		log.Printf("Invalid %s message from %s: %q", pc.codec.Name(), from, input)
+		pc.sendError(0, ErrorInvalidMessage, err.Error())
+		return msg, false
+	}
+	if msg.Version != PROTOCOL_VERSION {
+		pc.sendError(msg.Seq, ErrorUnsupportedVersion, fmt.Sprintf("server speaks version %d", PROTOCOL_VERSION))
+		return msg, false
+	}
+	return msg, true
+}
+
+// reject tells the client why its message was turned down
+func (pc *playerConn) reject(from string, msg Envelope[noPayload], err error) {
+	log.Printf("Rejected %s from %s: %v", msg.Type, from, err)
+	code := ErrorInvalidPayload
+	if pe, ok := err.(*protocolError); ok {
+		code = pe.code
+	}
+	pc.sendError(msg.Seq, code, err.Error())
+}
+
+// protocolError carries the error code reported to the client
+type protocolError struct {
+	code    string
+	message string
+}
+
+func (e *protocolError) Error() string {
+	return e.message
+}
+
+// decodePayload decodes a whole message into the envelope of its payload type
+func decodePayload[T any](wc wireCodec, input []byte) (Envelope[T], error) {
+	var msg Envelope[T]
+	if err := wc.Unmarshal(input, &msg); err != nil {
+		return msg, fmt.Errorf("invalid payload: %v", err)
+	}
+	return msg, nil
+}
+
+func (ss *SnakeService) handleMove(matchId, playerId string, wc wireCodec, input []byte) error {
+	move, err := decodePayload[PlayerMove](wc, input)
+	if err != nil {
+		return err
+	}
+	direction := strToDirection(string(move.Payload.Direction))
+	if direction == "" {
+		return fmt.Errorf("unknown direction %q", move.Payload.Direction)
+	}
+	log.Printf("Move %d from %s in match %s: %s", move.Seq, playerId, matchId, direction)
+	if err := ss.ExecuteMovement(matchId, playerId, direction, move.Seq); err != nil {
+		return &protocolError{code: ErrorMoveRejected, message: err.Error()}
 	}
-	log.Printf("Move from %s in match %s: %s", playerId, matchId, move.Direction)
-	service := GetSnakeService()
-	service.ExecuteMovement(matchId, playerId, strToDirection(move.Direction))
+	return nil
 }
 
 func strToDirection(dir string) Direction {
@@ -203,64 +477,104 @@ func strToDirection(dir string) Direction {
 	}
 }
 
-func handleChat(matchId, playerId string, input []byte) {
-	var chat PlayerChat
-	if err := json.Unmarshal(input, &chat); err != nil {
-		log.Printf("invalid chat message from %s: %s", playerId, string(input))
-		return
+func (ss *SnakeService) handleChat(matchId, playerId string, wc wireCodec, input []byte) error {
+	chat, err := decodePayload[PlayerChat](wc, input)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(chat.Payload.Message) == "" {
+		return fmt.Errorf("empty chat message")
 	}
 
-	chat.From = playerId
-	chat.Type = "chat"
-
-	broadcastChatToMatch(matchId, chat)
+	chat.Payload.From = playerId
+	ss.broadcastChatToMatch(matchId, chat.Payload)
+	return nil
 }
 
-func startMatchLoopOnce(matchId string, playerIds []string) {
+func (ss *SnakeService) startMatchLoopOnce(matchId, gameId string, playerIds []string) {
 	activeMatchLock.Lock()
-	defer activeMatchLock.Unlock()
-
 	if activeMatches[matchId] {
+		activeMatchLock.Unlock()
 		log.Printf("Match loop already running for %s", matchId)
 		return
 	}
-
-	service := GetSnakeService()
-	service.StartGame(matchId, playerIds)
+	ss.StartGame(matchId, gameId, playerIds)
 	activeMatches[matchId] = true
+	startSpectatorFeed(matchId, ss.config.SpectatorDelay)
+	activeMatchLock.Unlock()
+
+	// The lock guards every match, so the store is written outside of it
+	if err := ss.matchStore.MarkMatchStarted(matchId, time.Now()); err != nil {
+		log.Printf("Failed to record start of match %s: %v", matchId, err)
+	}
 
 	go func() {
+		var tick int64
+		var emptySince time.Time
+		reason := GameOverNoPlayers
+
 		defer func() {
+			// Ended before the board goes, so a late joiner is turned away
+			// instead of starting a fresh game
+			if err := ss.matchStore.MarkMatchEnded(matchId, time.Now()); err != nil {
+				log.Printf("Failed to record end of match %s: %v", matchId, err)
+			}
+
+			// publish stamps the events with the game of the board, so they
+			// go out before the board is dropped
+			if results := ss.MatchResults(matchId); results != nil {
+				broadcastGameOver(matchId, tick, reason, results)
+				for _, r := range results {
+					// players that never joined didn't finish
+					if r.Placement != nil {
+						ss.publish(matchId, events.Event{Type: events.PlayerFinished, PlayerId: r.PlayerId, Value: *r.Placement})
+					}
+				}
+				ss.publish(matchId, events.Event{Type: events.MatchEnded, Players: playerIds, Results: results})
+			}
+			rec := ss.recordingOf(matchId)
+
 			activeMatchLock.Lock()
+			ss.EndGame(matchId)
 			delete(activeMatches, matchId)
 			activeMatchLock.Unlock()
-			log.Printf("Match loop ended for %s", matchId)
+
+			if rec != nil {
+				rec.Reason = reason
+				ss.saveReplay(rec)
+			}
+
+			// The match is over, players still connected are sent home
+			closeMatchConnections(matchId)
+			endSpectatorFeed(matchId)
+			forgetSessions(matchId)
+			log.Printf("Match loop ended for %s: %s", matchId, reason)
 		}()
 
 		log.Printf("Starting match loop for %s", matchId)
-		ticker100ms := time.NewTicker(100 * time.Millisecond)
-		ticker500ms := time.NewTicker(500 * time.Millisecond)
-		ticker1s := time.NewTicker(1 * time.Second)
-
-		defer ticker100ms.Stop()
-		defer ticker500ms.Stop()
-		defer ticker1s.Stop()
-
-		for {
-			select {
-			case <-ticker100ms.C:
-				broadcastBoardState(matchId)
-			case <-ticker1s.C:
-				service.GenerateFood(matchId)
-			case <-ticker500ms.C:
-				service.RunAllSnake(matchId)
+		ticker := time.NewTicker(TICK_INTERVAL)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			// simulate first so every broadcast shows the state of a whole tick
+			tick = ss.Tick(matchId)
+			ss.broadcastBoardState(matchId, tick)
+
+			if over := ss.GameOver(matchId); over != "" {
+				reason = over
+				return
 			}
 
 			matchConnMutex.RLock()
 			activePlayers := len(matchConnections[matchId])
 			matchConnMutex.RUnlock()
 
-			if activePlayers == 0 {
+			// give players who all dropped at once the chance to come back
+			if activePlayers > 0 {
+				emptySince = time.Time{}
+			} else if emptySince.IsZero() {
+				emptySince = time.Now()
+			} else if time.Since(emptySince) >= ss.config.ReconnectGrace {
 				log.Printf("No active player in match %s - stopping loop", matchId)
 				return
 			}
@@ -268,45 +582,62 @@ func startMatchLoopOnce(matchId string, playerIds []string) {
 	}()
 }
 
-func broadcastBoardState(matchId string) {
-	matchConnMutex.RLock()
-	playerIds := make([]string, 0, len(matchConnections[matchId]))
-	for pId := range matchConnections[matchId] {
-		playerIds = append(playerIds, pId)
+func (ss *SnakeService) broadcastBoardState(matchId string, tick int64) {
+	players, spectators := matchAudience(matchId)
+	if len(players) == 0 && len(spectators) == 0 {
+		return
 	}
-	matchConnMutex.RUnlock()
 
-	if len(playerIds) == 0 {
+	// Deltas are shared, snapshots show the board from the player's own snake
+	delta := ss.StateDelta(matchId)
+	if delta == nil {
 		return
 	}
+	feed := spectatorFeedOf(matchId)
+	spectatorCount := len(spectators)
+	var spectatorsChanged *int
+	if feed != nil && feed.spectatorCount != spectatorCount {
+		feed.spectatorCount = spectatorCount
+		spectatorsChanged = &spectatorCount
+	}
 
-	service := GetSnakeService()
-	boardState := service.GetBoardStats(matchId, playerIds[0])
-
-	stateJSON, err := json.Marshal(map[string]interface{}{
-		"type":  "update",
-		"state": boardState,
-	})
-	if err != nil {
-		log.Println("Error marshalling board state:", err)
-		return
+	acks := ss.InputAcks(matchId)
+	for playerId, pc := range players {
+		update := DeltaUpdate{Tick: tick, Ack: acks[playerId], Spectators: spectatorsChanged, BoardDelta: delta}
+		queueBoardState(matchId, playerId, pc, update, func() StateUpdate {
+			return StateUpdate{
+				Tick:       tick,
+				Ack:        acks[playerId],
+				Spectators: spectatorCount,
+				State:      ss.GetBoardStats(matchId, playerId),
+			}
+		})
 	}
 
-	broadcastToMatch(matchId, stateJSON)
+	// spectators have no snake, their snapshots show every snake as another's
+	if feed != nil && spectatorCount > 0 {
+		feed.push(feedEntry{state: &StateUpdate{
+			Tick:       tick,
+			Spectators: spectatorCount,
+			State:      ss.GetBoardStats(matchId, ""),
+		}, delta: &DeltaUpdate{Tick: tick, Spectators: spectatorsChanged, BoardDelta: delta}})
+	}
 }
 
-func getPlayersByMatchId(db *sql.DB, matchId string) ([]string, error) {
-	row := db.QueryRow(`SELECT players FROM matches WHERE matchId = ?`, matchId)
-
-	var playerList string
-	if err := row.Scan(&playerList); err != nil {
-		return nil, fmt.Errorf("failed to query players for match %s: %v", matchId, err)
+// queueBoardState sends a connection the delta of a tick, or the snapshot
+// when it asked for one
+func queueBoardState(matchId, id string, pc *playerConn, delta DeltaUpdate, snapshot func() StateUpdate) {
+	msgType, msg := MessageDelta, any(delta)
+	if takeSnapshotRequest(matchId, id) {
+		msgType, msg = MessageSnapshot, snapshot()
 	}
-
-	playerIds := strings.Split(playerList, ",")
-	for i := range playerIds {
-		playerIds[i] = strings.TrimSpace(playerIds[i])
+	data, err := pc.codec.Marshal(Envelope[any]{Type: msgType, Version: PROTOCOL_VERSION, Payload: msg})
+	if err != nil {
+		log.Printf("Error marshalling %s: %v", msgType, err)
+		return
 	}
-
-	return playerIds, nil
-}
\ No newline at end of file
+	// a client still behind on earlier states gets a snapshot once it catches up
+	if !pc.queueState(data) {
+		requestSnapshot(matchId, id)
+	}
+}
@@ -0,0 +1,179 @@
+package snake
+
+import (
+	"game-server/internal/events"
+	"game-server/internal/store"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// serveSnakeWs serves the snake sockets of ss and returns their base URL
+func serveSnakeWs(t *testing.T, ss *SnakeService) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", ss.WsHandler)
+	r.GET("/ws/spectate", ss.SpectateHandler)
+	r.GET("/ws/replay", ss.ReplayHandler)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dialMatch opens a connection without a handshake
+func dialMatch(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{SUBPROTOCOL_JSON}}
+	client, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// joinMatch connects a player and completes the handshake
+func joinMatch(t *testing.T, url, matchId, playerId, resumeToken string) (*websocket.Conn, Welcome) {
+	t.Helper()
+	return handshakeMatch(t, url+"/ws?matchId="+matchId+"&playerId="+playerId, resumeToken)
+}
+
+func handshakeMatch(t *testing.T, url, resumeToken string) (*websocket.Conn, Welcome) {
+	t.Helper()
+	client := dialMatch(t, url)
+
+	sendTestMessage(t, client, Envelope[Hello]{Type: MessageHello, Version: PROTOCOL_VERSION, Seq: 1, Payload: Hello{ResumeToken: resumeToken}})
+	welcome := readTestMessage[Welcome](t, client)
+	if welcome.Type != MessageWelcome {
+		t.Fatalf("%s got %q instead of a welcome", url, welcome.Type)
+	}
+	return client, welcome.Payload
+}
+
+// wantClose checks the server turned the connection away with code
+func wantClose(t *testing.T, client *websocket.Conn, code int) {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, code) {
+		t.Fatalf("connection ended with %v, want close code %d", err, code)
+	}
+}
+
+func TestReconnectGrace(t *testing.T) {
+	const grace = 200 * time.Millisecond
+	tests := []struct {
+		name     string
+		matchId  string
+		comeBack bool
+	}{
+		{name: "player stays away", matchId: "m-grace-away", comeBack: false},
+		{name: "player comes back", matchId: "m-grace-back", comeBack: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := newTestSnakeService()
+			ss.config.ReconnectGrace = grace
+			ss.matchStore.SaveMatch(store.Match{GameId: "snake", MatchId: tt.matchId, Players: []string{"a", "b"}, CreatedAt: time.Now()})
+			abandoned := make(chan events.Event, 1)
+			ss.bus.Subscribe(events.PlayerAbandoned, func(e events.Event) { abandoned <- e })
+
+			url := serveSnakeWs(t, ss)
+			a, welcome := joinMatch(t, url, tt.matchId, "a", "")
+			joinMatch(t, url, tt.matchId, "b", "")
+
+			a.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			a.Close()
+			if tt.comeBack {
+				if _, back := joinMatch(t, url, tt.matchId, "a", welcome.ResumeToken); !back.Resumed {
+					t.Fatal("reconnecting with the resume token did not resume the session")
+				}
+			}
+
+			select {
+			case e := <-abandoned:
+				if tt.comeBack {
+					t.Fatalf("%s abandoned although they came back within the grace window", e.PlayerId)
+				}
+				if e.PlayerId != "a" || e.Cause != DisconnectClosed || e.MatchId != tt.matchId {
+					t.Fatalf("got %+v, want a abandoning %s with cause %q", e, tt.matchId, DisconnectClosed)
+				}
+			case <-time.After(5 * grace):
+				if !tt.comeBack {
+					t.Fatal("no PlayerAbandoned once the grace window ran out")
+				}
+			}
+		})
+	}
+}
+
+func TestJoinRejections(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.matchStore.SaveMatch(store.Match{GameId: "snake", MatchId: "m-waiting", Players: []string{"a", "b"}, CreatedAt: time.Now()})
+	ss.matchStore.SaveMatch(store.Match{GameId: "snake", MatchId: "m-over", Players: []string{"a", "b"}, CreatedAt: time.Now()})
+	ss.matchStore.MarkMatchEnded("m-over", time.Now())
+	url := serveSnakeWs(t, ss)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantClose int
+	}{
+		{name: "unknown match", query: "matchId=m-unknown&playerId=a", wantClose: CloseMatchNotFound},
+		{name: "finished match", query: "matchId=m-over&playerId=a", wantClose: CloseMatchNotFound},
+		{name: "not a player", query: "matchId=m-waiting&playerId=x", wantClose: CloseForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantClose(t, dialMatch(t, url+"/ws?"+tt.query), tt.wantClose)
+		})
+	}
+}
+
+func TestMatchEndEventsCarryTheGame(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.config.ReconnectGrace = 50 * time.Millisecond
+	// c never joins
+	ss.matchStore.SaveMatch(store.Match{GameId: "snake", MatchId: "m-finished", Players: []string{"a", "b", "c"}, CreatedAt: time.Now()})
+	ended := make(chan events.Event, 4)
+	ss.bus.Subscribe(events.PlayerFinished, func(e events.Event) { ended <- e })
+	ss.bus.Subscribe(events.MatchEnded, func(e events.Event) { ended <- e })
+
+	url := serveSnakeWs(t, ss)
+	a, _ := joinMatch(t, url, "m-finished", "a", "")
+	b, _ := joinMatch(t, url, "m-finished", "b", "")
+	// with everyone gone past the grace window the match ends
+	a.Close()
+	b.Close()
+
+	// the leaderboard and achievements file results under the game
+	finished := map[string]bool{}
+	for len(finished) < 3 {
+		select {
+		case e := <-ended:
+			if e.GameId != "snake" || e.MatchId != "m-finished" {
+				t.Fatalf("%s for %q went out for game %q of match %q", e.Type, e.PlayerId, e.GameId, e.MatchId)
+			}
+			if e.Type == events.MatchEnded {
+				for _, r := range e.Results {
+					if (r.PlayerId == "c") != (r.Placement == nil) {
+						t.Errorf("%s placed %v in %+v", r.PlayerId, r.Placement, e.Results)
+					}
+				}
+			}
+			finished[string(e.Type)+e.PlayerId] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %v when the match ended", finished)
+		}
+	}
+	// a player that never joined didn't finish
+	select {
+	case e := <-ended:
+		t.Fatalf("got %s for %q", e.Type, e.PlayerId)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
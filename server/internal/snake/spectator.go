@@ -0,0 +1,239 @@
+package snake
+
+import (
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// spectatorFeeds is guarded by matchConnMutex
+var spectatorFeeds = make(map[string]*spectatorFeed)
+
+// feedEntry is a board state or a message waiting out the spectator delay
+type feedEntry struct {
+	due time.Time
+	// state and delta are set for board states, msgType and payload otherwise
+	state   *StateUpdate
+	delta   *DeltaUpdate
+	msgType string
+	payload any
+	// resync sends every spectator a snapshot, an earlier entry was lost
+	resync bool
+}
+
+// spectatorFeed replays a match to its spectators SpectatorDelay behind the
+// players, so watching along can't tell a player where the others are
+type spectatorFeed struct {
+	delay   time.Duration
+	entries chan feedEntry
+	// spectatorCount is the count last sent, only the match loop uses it
+	spectatorCount int
+
+	mu    sync.Mutex
+	ended bool
+	lost  bool
+}
+
+func newSpectatorFeed(delay time.Duration) *spectatorFeed {
+	// room for every tick within the delay, plus the messages sent meanwhile
+	size := 2*int(delay/TICK_INTERVAL) + SEND_QUEUE_SIZE
+	return &spectatorFeed{
+		delay:   delay,
+		entries: make(chan feedEntry, size),
+	}
+}
+
+// push schedules an entry for delivery, it never blocks the match
+func (f *spectatorFeed) push(entry feedEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ended {
+		return
+	}
+	entry.due = time.Now().Add(f.delay)
+	if entry.state != nil && f.lost {
+		entry.resync = true
+	}
+	select {
+	case f.entries <- entry:
+		if entry.resync {
+			f.lost = false
+		}
+	default:
+		log.Printf("Spectator feed is full, dropping %s", entry.msgType)
+		f.lost = true
+	}
+}
+
+// end lets the feed deliver what is left and then close the spectators
+func (f *spectatorFeed) end() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.ended {
+		f.ended = true
+		close(f.entries)
+	}
+}
+
+func startSpectatorFeed(matchId string, delay time.Duration) {
+	feed := newSpectatorFeed(delay)
+	matchConnMutex.Lock()
+	spectatorFeeds[matchId] = feed
+	matchConnMutex.Unlock()
+
+	go runSpectatorFeed(matchId, feed)
+}
+
+func endSpectatorFeed(matchId string) {
+	if feed := spectatorFeedOf(matchId); feed != nil {
+		feed.end()
+	}
+}
+
+func spectatorFeedOf(matchId string) *spectatorFeed {
+	matchConnMutex.RLock()
+	defer matchConnMutex.RUnlock()
+
+	return spectatorFeeds[matchId]
+}
+
+func runSpectatorFeed(matchId string, feed *spectatorFeed) {
+	for entry := range feed.entries {
+		time.Sleep(time.Until(entry.due))
+
+		_, spectators := matchAudience(matchId)
+		if entry.state == nil {
+			broadcast(matchId, slices.Collect(maps.Values(spectators)), entry.msgType, entry.payload)
+			continue
+		}
+		for spectatorId, pc := range spectators {
+			if entry.resync {
+				requestSnapshot(matchId, spectatorId)
+			}
+			queueBoardState(matchId, spectatorId, pc, *entry.delta, func() StateUpdate {
+				return *entry.state
+			})
+		}
+	}
+
+	// The spectators have seen the match to its end and are sent home
+	matchConnMutex.Lock()
+	delete(spectatorFeeds, matchId)
+	delete(snapshotRequests, matchId)
+	spectators := maps.Clone(matchSpectators[matchId])
+	matchConnMutex.Unlock()
+
+	for _, pc := range spectators {
+		pc.closeWith(websocket.CloseNormalClosure, "game over")
+	}
+}
+
+// SpectateHandler streams a running match to someone who isn't playing it.
+// Spectators see the whole board, but can't move or chat.
+func (ss *SnakeService) SpectateHandler(c *gin.Context) {
+	matchId := c.Query("matchId")
+	spectatorId := c.Query("spectatorId")
+
+	if matchId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "matchId required"})
+		return
+	}
+	if spectatorId == "" {
+		spectatorId = "spectator-" + uuid.NewString()
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println("Upgrading error:", err)
+		return
+	}
+	pc := newPlayerConn(conn, codecFor(conn.Subprotocol()), ss.config)
+	defer pc.closeWith(websocket.CloseNormalClosure, "")
+	pc.role = RoleSpectator
+
+	match, ok := ss.loadOpenMatch(pc, matchId)
+	if !ok {
+		return
+	}
+	if slices.Contains(match.Players, spectatorId) {
+		pc.closeWith(CloseForbidden, "players can't spectate their own match")
+		return
+	}
+	if !isMatchActive(matchId) {
+		pc.closeWith(CloseMatchNotFound, "match is not running")
+		return
+	}
+
+	if err := pc.handshake(spectatorId, matchId); err != nil {
+		log.Printf("Handshake with spectator %s failed: %v", spectatorId, err)
+		return
+	}
+
+	if !registerSpectator(matchId, spectatorId, pc) {
+		pc.closeWith(CloseMatchNotFound, "match is over")
+		return
+	}
+	defer unregisterSpectator(matchId, spectatorId, pc)
+	log.Printf("Spectator %s watching match %s using %s", spectatorId, matchId, pc.codec.Name())
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			pc.readFailed(err)
+			log.Printf("Spectator %s left match %s (%s): %v", spectatorId, matchId, pc.disconnectReason(), err)
+			return
+		}
+		pc.keepAlive()
+		ss.handlePlayerInput(matchId, spectatorId, pc, message)
+	}
+}
+
+// registerSpectator adds a spectator, a newer connection under the same id
+// wins. It reports false once the match has nothing left to show.
+func registerSpectator(matchId, spectatorId string, pc *playerConn) bool {
+	matchConnMutex.Lock()
+	defer matchConnMutex.Unlock()
+
+	if spectatorFeeds[matchId] == nil {
+		return false
+	}
+	if matchSpectators[matchId] == nil {
+		matchSpectators[matchId] = make(map[string]*playerConn)
+	}
+	if old, ok := matchSpectators[matchId][spectatorId]; ok && old != pc {
+		old.closeWith(CloseSessionReplaced, "replaced by a new connection")
+	}
+	matchSpectators[matchId][spectatorId] = pc
+	requestSnapshotLocked(matchId, spectatorId)
+	return true
+}
+
+// spectatorCount is how many people are watching the match right now
+func spectatorCount(matchId string) int {
+	matchConnMutex.RLock()
+	defer matchConnMutex.RUnlock()
+
+	return len(matchSpectators[matchId])
+}
+
+func unregisterSpectator(matchId, spectatorId string, pc *playerConn) {
+	matchConnMutex.Lock()
+	defer matchConnMutex.Unlock()
+
+	if matchSpectators[matchId][spectatorId] == pc {
+		delete(matchSpectators[matchId], spectatorId)
+		delete(snapshotRequests[matchId], spectatorId)
+		if len(matchSpectators[matchId]) == 0 {
+			delete(matchSpectators, matchId)
+		}
+	}
+}
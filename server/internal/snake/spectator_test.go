@@ -0,0 +1,115 @@
+package snake
+
+import (
+	"fmt"
+	"game-server/internal/store"
+	"testing"
+	"time"
+)
+
+func TestSpectateRejections(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.matchStore.SaveMatch(store.Match{GameId: "snake", MatchId: "m-not-running", Players: []string{"a", "b"}, CreatedAt: time.Now()})
+	ss.matchStore.SaveMatch(store.Match{GameId: "snake", MatchId: "m-spectate-over", Players: []string{"a", "b"}, CreatedAt: time.Now()})
+	ss.matchStore.MarkMatchEnded("m-spectate-over", time.Now())
+	url := serveSnakeWs(t, ss)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantClose int
+	}{
+		{name: "unknown match", query: "matchId=m-unknown&spectatorId=x", wantClose: CloseMatchNotFound},
+		{name: "finished match", query: "matchId=m-spectate-over&spectatorId=x", wantClose: CloseMatchNotFound},
+		// players watching their own match could see past the delay
+		{name: "player of the match", query: "matchId=m-not-running&spectatorId=a", wantClose: CloseForbidden},
+		{name: "match not running", query: "matchId=m-not-running&spectatorId=x", wantClose: CloseMatchNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantClose(t, dialMatch(t, url+"/ws/spectate?"+tt.query), tt.wantClose)
+		})
+	}
+}
+
+func TestSpectatorWatchesBehind(t *testing.T) {
+	ss := newTestSnakeService()
+	ss.config.SpectatorDelay = 300 * time.Millisecond
+	ss.matchStore.SaveMatch(store.Match{GameId: "snake", MatchId: "m-spectated", Players: []string{"a", "b"}, CreatedAt: time.Now()})
+	url := serveSnakeWs(t, ss)
+
+	joinMatch(t, url, "m-spectated", "a", "")
+	joined := time.Now()
+	spectator, welcome := handshakeMatch(t, url+"/ws/spectate?matchId=m-spectated&spectatorId=x", "")
+	if welcome.Role != RoleSpectator || welcome.ResumeToken != "" {
+		t.Fatalf("spectator welcome = %+v, want the spectator role and no resume token", welcome)
+	}
+
+	sendTestMessage(t, spectator, Envelope[PlayerMove]{Type: MessageMove, Version: PROTOCOL_VERSION, Seq: 2, Payload: PlayerMove{UP}})
+	if reply := readTestMessage[ErrorNotice](t, spectator); reply.Payload.Code != ErrorForbidden || reply.Seq != 2 {
+		t.Fatalf("spectator move got %+v, want a %s error", reply, ErrorForbidden)
+	}
+
+	snapshot := readTestMessage[StateUpdate](t, spectator)
+	if snapshot.Type != MessageSnapshot {
+		t.Fatalf("spectator got %q first, want a snapshot", snapshot.Type)
+	}
+	if waited := time.Since(joined); waited < ss.config.SpectatorDelay {
+		t.Errorf("first snapshot came after %v, want at least the %v delay", waited, ss.config.SpectatorDelay)
+	}
+	state := snapshot.Payload.State
+	if snapshot.Payload.Spectators != 1 || state.PlayerSnake.PlayerId != "" || len(state.OtherSnakes) != 1 {
+		t.Fatalf("spectator snapshot = %+v, want one spectator and a's snake as another's", snapshot.Payload)
+	}
+}
+
+func TestSpectatorChat(t *testing.T) {
+	for _, chat := range []bool{true, false} {
+		ss := newTestSnakeService()
+		ss.config.SpectatorChat = chat
+		matchId := fmt.Sprintf("m-chat-%v", chat)
+		startSpectatorFeed(matchId, time.Minute)
+		player, _ := servePlayerConn(t, SUBPROTOCOL_JSON, false)
+		spectator, _ := servePlayerConn(t, SUBPROTOCOL_JSON, false)
+		registerConnection(matchId, "a", player)
+		if !registerSpectator(matchId, "x", spectator) {
+			t.Fatal("spectator was turned away from a running feed")
+		}
+
+		ss.broadcastChatToMatch(matchId, PlayerChat{From: "a", Message: "gg"})
+		got := len(spectator.outbox)
+		unregisterConnection(matchId, "a", player)
+		unregisterSpectator(matchId, "x", spectator)
+		endSpectatorFeed(matchId)
+
+		if len(player.outbox) != 1 || got != map[bool]int{true: 1, false: 0}[chat] {
+			t.Errorf("with spectator chat %v the player got %d messages and the spectator %d", chat, len(player.outbox), got)
+		}
+	}
+}
+
+func TestSpectatorFeedResyncsAfterLoss(t *testing.T) {
+	feed := newSpectatorFeed(0)
+	for range cap(feed.entries) + 1 {
+		feed.push(feedEntry{msgType: MessageChat})
+	}
+	if !feed.lost {
+		t.Fatal("overflowing the feed did not mark an entry as lost")
+	}
+
+	<-feed.entries
+	feed.push(feedEntry{state: &StateUpdate{}, delta: &DeltaUpdate{}})
+	var last feedEntry
+	for range cap(feed.entries) {
+		last = <-feed.entries
+	}
+	if !last.resync || feed.lost {
+		t.Fatalf("first state after the loss has resync %v, feed still lost %v", last.resync, feed.lost)
+	}
+
+	feed.end()
+	feed.push(feedEntry{msgType: MessageChat})
+	if _, open := <-feed.entries; open {
+		t.Fatal("an ended feed took a new entry")
+	}
+}
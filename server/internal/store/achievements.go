@@ -0,0 +1,50 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func (s *SQLStore) ListAchievements(playerId string) ([]PlayerAchievement, error) {
+	rows, err := s.query(`
+		SELECT achievementId, progress, unlockedAt
+		FROM player_achievements WHERE playerId = ?
+		ORDER BY achievementId
+	`, playerId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list achievements: %v", err)
+	}
+	defer rows.Close()
+
+	achievements := make([]PlayerAchievement, 0)
+	for rows.Next() {
+		a := PlayerAchievement{PlayerId: playerId}
+		var unlockedAt sql.NullInt64
+		if err := rows.Scan(&a.AchievementId, &a.Progress, &unlockedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan achievement: %v", err)
+		}
+		a.UnlockedAt = timeOrZero(unlockedAt.Int64)
+		achievements = append(achievements, a)
+	}
+	return achievements, rows.Err()
+}
+
+func (s *SQLStore) SaveAchievement(a PlayerAchievement) error {
+	var unlockedAt any
+	if !a.UnlockedAt.IsZero() {
+		unlockedAt = a.UnlockedAt.Unix()
+	}
+
+	_, err := s.exec(`
+		INSERT INTO player_achievements (playerId, achievementId, progress, unlockedAt)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(playerId, achievementId) DO UPDATE SET
+			progress = excluded.progress,
+			unlockedAt = excluded.unlockedAt
+	`, a.PlayerId, a.AchievementId, a.Progress, unlockedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to save achievement: %v", err)
+	}
+	return nil
+}
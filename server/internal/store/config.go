@@ -0,0 +1,51 @@
+package store
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+// Config selects the storage backend
+type Config struct {
+	Driver string
+	// File path for sqlite, connection string for postgres
+	DSN string
+}
+
+// ConfigFromEnv reads DB_DRIVER and DB_DSN, defaulting to ./matches.db on sqlite
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Driver: os.Getenv("DB_DRIVER"),
+		DSN:    os.Getenv("DB_DSN"),
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = DriverSQLite
+	}
+	if cfg.DSN == "" && cfg.Driver == DriverSQLite {
+		cfg.DSN = "./matches.db"
+	}
+	return cfg
+}
+
+// Open creates the store selected by cfg
+func Open(cfg Config) (Store, error) {
+	switch cfg.Driver {
+	case DriverSQLite:
+		return NewSQLiteStore(cfg.DSN)
+	case DriverPostgres:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("DB_DSN is required for the postgres driver")
+		}
+		return NewPostgresStore(cfg.DSN)
+	case DriverMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown DB driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (s *SQLStore) SaveMatchResults(matchId string, results []PlayerResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save match results: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range results {
+		_, err := tx.Exec(s.rebind(`
+			UPDATE match_players SET finalScore = ?, placement = ?, deathReason = ?,
+				maxLength = ?, foodEaten = ?, kills = ?, timeAliveMs = ?
+			WHERE matchId = ? AND playerId = ?
+		`), r.FinalScore, r.Placement, r.DeathReason,
+			r.MaxLength, r.FoodEaten, r.Kills, r.TimeAlive.Milliseconds(),
+			matchId, r.PlayerId)
+		if err != nil {
+			return fmt.Errorf("failed to save result for %v: %v", r.PlayerId, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save match results: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetRatings(gameId string, playerIds []string) (map[string]Rating, error) {
+	ratings := make(map[string]Rating, len(playerIds))
+	if len(playerIds) == 0 {
+		return ratings, nil
+	}
+
+	args := []any{gameId}
+	for _, playerId := range playerIds {
+		args = append(args, playerId)
+	}
+	rows, err := s.query(`
+		SELECT playerId, rating, games FROM player_ratings
+		WHERE gameId = ? AND playerId IN (`+placeholders(len(playerIds))+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ratings: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := Rating{GameId: gameId}
+		if err := rows.Scan(&r.PlayerId, &r.Rating, &r.Games); err != nil {
+			return nil, fmt.Errorf("failed to scan rating: %v", err)
+		}
+		ratings[r.PlayerId] = r
+	}
+	return ratings, rows.Err()
+}
+
+func (s *SQLStore) SaveRatings(ratings []Rating) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save ratings: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range ratings {
+		_, err := tx.Exec(s.rebind(`
+			INSERT INTO player_ratings (playerId, gameId, rating, games)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(playerId, gameId) DO UPDATE SET
+				rating = excluded.rating,
+				games = excluded.games
+		`), r.PlayerId, r.GameId, r.Rating, r.Games)
+		if err != nil {
+			return fmt.Errorf("failed to save rating for %v: %v", r.PlayerId, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save ratings: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Leaderboard(q LeaderboardQuery) ([]LeaderboardEntry, error) {
+	// Results of matches in the window, one row per player
+	played := `
+		FROM match_players mp
+		JOIN matches m ON m.matchId = mp.matchId
+		WHERE m.gameId = ? AND mp.placement IS NOT NULL`
+	args := []any{q.GameId}
+	if !q.Since.IsZero() {
+		played += ` AND m.endedAt >= ?`
+		args = append(args, q.Since.Unix())
+	}
+
+	var standings string
+	switch q.Metric {
+	case MetricScore:
+		standings = `SELECT mp.playerId, MAX(mp.finalScore) AS value` + played + ` GROUP BY mp.playerId`
+	case MetricWins:
+		standings = `SELECT mp.playerId, SUM(CASE WHEN mp.placement = 1 THEN 1 ELSE 0 END) AS value` + played + ` GROUP BY mp.playerId`
+	case MetricRating:
+		standings = `
+			SELECT r.playerId, r.rating AS value FROM player_ratings r
+			WHERE r.gameId = ? AND r.playerId IN (SELECT DISTINCT mp.playerId` + played + `)`
+		args = append([]any{q.GameId}, args...)
+	default:
+		return nil, fmt.Errorf("unknown leaderboard metric %q", q.Metric)
+	}
+	args = append(args, q.Limit, q.PlayerId)
+
+	rows, err := s.query(`
+		SELECT rnk, playerId, value FROM (
+			SELECT playerId, value,
+				RANK() OVER (ORDER BY value DESC) AS rnk,
+				ROW_NUMBER() OVER (ORDER BY value DESC, playerId) AS position
+			FROM (`+standings+`) standings
+		) ranked
+		WHERE position <= ? OR playerId = ?
+		ORDER BY position
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load leaderboard: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]LeaderboardEntry, 0)
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.Rank, &e.PlayerId, &e.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,429 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+type playerStatus struct {
+	status  string
+	matchId string
+}
+
+type memoryMatch struct {
+	match     Match
+	seq       int64
+	startedAt time.Time
+	endedAt   time.Time
+	players   []MatchPlayer
+	results   map[string]PlayerResult
+}
+
+// MemoryStore keeps everything in process memory. It is meant for tests and
+// local runs where nothing needs to survive a restart.
+type MemoryStore struct {
+	matches      map[string]*memoryMatch
+	matchOrder   []string
+	statuses     map[string]playerStatus
+	penalties    map[string]PlayerPenalty
+	ratings      map[string]Rating                       // gameId/playerId -> rating
+	achievements map[string]map[string]PlayerAchievement // playerId -> achievementId -> progress
+	replays      map[string]MatchReplay
+	mu           sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		matches:      make(map[string]*memoryMatch),
+		statuses:     make(map[string]playerStatus),
+		penalties:    make(map[string]PlayerPenalty),
+		ratings:      make(map[string]Rating),
+		achievements: make(map[string]map[string]PlayerAchievement),
+		replays:      make(map[string]MatchReplay),
+	}
+}
+
+func (s *MemoryStore) SaveMatch(match Match) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.matches[match.MatchId]; exists {
+		return fmt.Errorf("failed to insert match: match %v already exists", match.MatchId)
+	}
+
+	match.Players = slices.Clone(match.Players)
+	players := make([]MatchPlayer, 0, len(match.Players))
+	for seat, playerId := range match.Players {
+		players = append(players, MatchPlayer{PlayerId: playerId, Seat: seat})
+	}
+
+	s.matchOrder = append(s.matchOrder, match.MatchId)
+	s.matches[match.MatchId] = &memoryMatch{
+		match:   match,
+		seq:     int64(len(s.matchOrder)),
+		players: players,
+		results: make(map[string]PlayerResult),
+	}
+	return nil
+}
+
+func (s *MemoryStore) LoadMatch(matchId string) (*Match, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.matches[matchId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	match := m.match
+	match.Players = slices.Clone(match.Players)
+	match.EndedAt = m.endedAt
+	return &match, nil
+}
+
+func (s *MemoryStore) ListMatches() ([]Match, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := make([]Match, 0, len(s.matchOrder))
+	for _, matchId := range s.matchOrder {
+		match := s.matches[matchId].match
+		match.Players = slices.Clone(match.Players)
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+func (s *MemoryStore) ListPlayerMatches(q PlayerMatchQuery) ([]PlayerMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := make([]PlayerMatch, 0)
+	for _, m := range s.matches {
+		idx := slices.IndexFunc(m.players, func(mp MatchPlayer) bool {
+			return mp.PlayerId == q.PlayerId
+		})
+		if idx < 0 {
+			continue
+		}
+
+		player := m.players[idx]
+		cursor := MatchCursor{CreatedAt: unixOrZero(m.match.CreatedAt), Seq: m.seq}
+		switch {
+		case q.GameId != "" && m.match.GameId != q.GameId,
+			!q.From.IsZero() && cursor.CreatedAt < q.From.Unix(),
+			!q.To.IsZero() && cursor.CreatedAt >= q.To.Unix(),
+			q.Result != "" && player.Result() != q.Result,
+			q.After != nil && compareCursors(cursor, *q.After) >= 0:
+			continue
+		}
+
+		opponents := make([]MatchPlayer, 0, len(m.players)-1)
+		for _, mp := range m.players {
+			if mp.PlayerId != q.PlayerId {
+				opponents = append(opponents, mp)
+			}
+		}
+		matches = append(matches, PlayerMatch{
+			MatchId:   m.match.MatchId,
+			GameId:    m.match.GameId,
+			CreatedAt: m.match.CreatedAt,
+			StartedAt: m.startedAt,
+			EndedAt:   m.endedAt,
+			Player:    player,
+			Opponents: opponents,
+			Cursor:    cursor,
+		})
+	}
+
+	// newest first
+	slices.SortFunc(matches, func(a, b PlayerMatch) int {
+		return compareCursors(b.Cursor, a.Cursor)
+	})
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+	return matches, nil
+}
+
+func (s *MemoryStore) MarkMatchStarted(matchId string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.matches[matchId]; ok && m.startedAt.IsZero() {
+		m.startedAt = at
+	}
+	return nil
+}
+
+func (s *MemoryStore) MarkMatchEnded(matchId string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.matches[matchId]; ok {
+		m.endedAt = at
+	}
+	return nil
+}
+
+func (s *MemoryStore) SaveMatchResults(matchId string, results []PlayerResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.matches[matchId]
+	if !ok {
+		return nil
+	}
+	for _, r := range results {
+		m.results[r.PlayerId] = r
+		for i := range m.players {
+			if m.players[i].PlayerId == r.PlayerId {
+				m.players[i].FinalScore = &r.FinalScore
+				m.players[i].Placement = r.Placement
+				m.players[i].DeathReason = r.DeathReason
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetRatings(gameId string, playerIds []string) (map[string]Rating, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ratings := make(map[string]Rating, len(playerIds))
+	for _, playerId := range playerIds {
+		if r, ok := s.ratings[gameId+"/"+playerId]; ok {
+			ratings[playerId] = r
+		}
+	}
+	return ratings, nil
+}
+
+func (s *MemoryStore) SaveRatings(ratings []Rating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range ratings {
+		s.ratings[r.GameId+"/"+r.PlayerId] = r
+	}
+	return nil
+}
+
+func (s *MemoryStore) Leaderboard(q LeaderboardQuery) ([]LeaderboardEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	standings := make(map[string]int)
+	for _, m := range s.matches {
+		if m.match.GameId != q.GameId || (!q.Since.IsZero() && m.endedAt.Before(q.Since)) {
+			continue
+		}
+		for _, mp := range m.players {
+			if mp.Placement == nil {
+				continue
+			}
+			value, seen := standings[mp.PlayerId]
+			switch q.Metric {
+			case MetricScore:
+				if !seen || *mp.FinalScore > value {
+					standings[mp.PlayerId] = *mp.FinalScore
+				}
+			case MetricWins:
+				if *mp.Placement == 1 {
+					value++
+				}
+				standings[mp.PlayerId] = value
+			case MetricRating:
+				if r, ok := s.ratings[q.GameId+"/"+mp.PlayerId]; ok {
+					standings[mp.PlayerId] = r.Rating
+				}
+			default:
+				return nil, fmt.Errorf("unknown leaderboard metric %q", q.Metric)
+			}
+		}
+	}
+
+	ranked := make([]LeaderboardEntry, 0, len(standings))
+	for playerId, value := range standings {
+		ranked = append(ranked, LeaderboardEntry{PlayerId: playerId, Value: value})
+	}
+	slices.SortFunc(ranked, func(a, b LeaderboardEntry) int {
+		if c := cmp.Compare(b.Value, a.Value); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.PlayerId, b.PlayerId)
+	})
+
+	entries := make([]LeaderboardEntry, 0, q.Limit+1)
+	for i := range ranked {
+		// ties share the rank of the first player with that value
+		ranked[i].Rank = i + 1
+		if i > 0 && ranked[i].Value == ranked[i-1].Value {
+			ranked[i].Rank = ranked[i-1].Rank
+		}
+		if i < q.Limit || ranked[i].PlayerId == q.PlayerId {
+			entries = append(entries, ranked[i])
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) SnakeStats(playerId string, gameIds []string) (*SnakeStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &SnakeStats{
+		PlayerId:      playerId,
+		DeathsByCause: make(map[string]int),
+	}
+	var totalScore int
+	var timeAlive time.Duration
+	for _, m := range s.matches {
+		r, ok := m.results[playerId]
+		// Only finished matches have a placement
+		if !ok || r.Placement == nil || !slices.Contains(gameIds, m.match.GameId) {
+			continue
+		}
+
+		stats.GamesPlayed++
+		if *r.Placement == 1 {
+			stats.Wins++
+		}
+		totalScore += r.FinalScore
+		stats.BestScore = max(stats.BestScore, r.FinalScore)
+		stats.LongestLength = max(stats.LongestLength, r.MaxLength)
+		stats.FoodEaten += r.FoodEaten
+		stats.Kills += r.Kills
+		timeAlive += r.TimeAlive
+		if r.DeathReason != "" {
+			stats.DeathsByCause[r.DeathReason]++
+		}
+	}
+
+	if stats.GamesPlayed > 0 {
+		stats.AverageScore = float64(totalScore) / float64(stats.GamesPlayed)
+	}
+	stats.TimeAliveSeconds = int64(timeAlive.Seconds())
+	return stats, nil
+}
+
+func (s *MemoryStore) GetPlayerStatus(playerId string) (string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ps, ok := s.statuses[playerId]
+	if !ok {
+		return "", "", ErrNotFound
+	}
+	return ps.status, ps.matchId, nil
+}
+
+func (s *MemoryStore) UpdatePlayerStatus(playerId, status, matchId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statuses[playerId] = playerStatus{status: status, matchId: matchId}
+	return nil
+}
+
+func (s *MemoryStore) GetPenalty(playerId string) (*PlayerPenalty, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	penalty, ok := s.penalties[playerId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &penalty, nil
+}
+
+func (s *MemoryStore) SavePenalty(penalty PlayerPenalty) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.penalties[penalty.PlayerId] = penalty
+	return nil
+}
+
+func (s *MemoryStore) DeletePenalty(playerId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.penalties, playerId)
+	return nil
+}
+
+func (s *MemoryStore) ListPenalties() ([]PlayerPenalty, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	penalties := make([]PlayerPenalty, 0, len(s.penalties))
+	for _, penalty := range s.penalties {
+		penalties = append(penalties, penalty)
+	}
+	slices.SortFunc(penalties, func(a, b PlayerPenalty) int {
+		return b.LastOffenseAt.Compare(a.LastOffenseAt)
+	})
+	return penalties, nil
+}
+
+func (s *MemoryStore) ListAchievements(playerId string) ([]PlayerAchievement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	achievements := make([]PlayerAchievement, 0, len(s.achievements[playerId]))
+	for _, a := range s.achievements[playerId] {
+		achievements = append(achievements, a)
+	}
+	slices.SortFunc(achievements, func(a, b PlayerAchievement) int {
+		return cmp.Compare(a.AchievementId, b.AchievementId)
+	})
+	return achievements, nil
+}
+
+func (s *MemoryStore) SaveAchievement(achievement PlayerAchievement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.achievements[achievement.PlayerId] == nil {
+		s.achievements[achievement.PlayerId] = make(map[string]PlayerAchievement)
+	}
+	s.achievements[achievement.PlayerId][achievement.AchievementId] = achievement
+	return nil
+}
+
+func (s *MemoryStore) SaveReplay(replay MatchReplay) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	replay.Data = slices.Clone(replay.Data)
+	s.replays[replay.MatchId] = replay
+	return nil
+}
+
+func (s *MemoryStore) LoadReplay(matchId string) (*MatchReplay, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	replay, ok := s.replays[matchId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	replay.Data = slices.Clone(replay.Data)
+	return &replay, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func compareCursors(a, b MatchCursor) int {
+	if c := cmp.Compare(a.CreatedAt, b.CreatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Seq, b.Seq)
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Migration is one numbered schema change. Up and Down run inside the same
+// transaction that records the change in schema_migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx, d dialect) error
+	Down    func(tx *sql.Tx, d dialect) error
+}
+
+// Migrator is implemented by stores that keep a versioned schema
+type Migrator interface {
+	SchemaVersion() (int, error)
+	MigrateTo(version int) error
+}
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// LatestSchemaVersion is the schema version this binary was built for
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// PrepareSchema refuses to run against a database migrated by a newer binary
+// and applies any pending migrations otherwise
+func PrepareSchema(s Store) error {
+	m, ok := s.(Migrator)
+	if !ok {
+		return nil
+	}
+
+	version, err := m.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaTooNew, version, LatestSchemaVersion())
+	}
+	if version < LatestSchemaVersion() {
+		return m.MigrateTo(LatestSchemaVersion())
+	}
+	return nil
+}
+
+// sqlMigration builds migration steps from plain SQL. {{id}} and {{bigint}}
+// are replaced with the column types of the store's dialect.
+func sqlMigration(query string) func(tx *sql.Tx, d dialect) error {
+	return func(tx *sql.Tx, d dialect) error {
+		_, err := tx.Exec(expandTypes(query, d))
+		return err
+	}
+}
+
+func expandTypes(query string, d dialect) string {
+	id, bigint := "INTEGER PRIMARY KEY AUTOINCREMENT", "INTEGER"
+	if d == dialectPostgres {
+		id, bigint = "SERIAL PRIMARY KEY", "BIGINT"
+	}
+	return strings.NewReplacer("{{id}}", id, "{{bigint}}", bigint).Replace(query)
+}
+
+func (s *SQLStore) ensureMigrationsTable() error {
+	_, err := s.db.Exec(expandTypes(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			appliedAt {{bigint}} NOT NULL
+		)
+	`, s.dialect))
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration, 0 for an empty database
+func (s *SQLStore) SchemaVersion() (int, error) {
+	if err := s.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := s.queryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return int(version.Int64), nil
+}
+
+// MigrateTo applies up migrations or rolls back down migrations until the
+// database is at the target version
+func (s *SQLStore) MigrateTo(target int) error {
+	if target < 0 || target > LatestSchemaVersion() {
+		return fmt.Errorf("unknown schema version %d, latest is %d", target, LatestSchemaVersion())
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("%w: database is at version %d", ErrSchemaTooNew, current)
+	}
+
+	for _, m := range migrations {
+		if m.Version > current && m.Version <= target {
+			if err := s.applyMigration(m, true); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= current && m.Version > target {
+			if err := s.applyMigration(m, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) applyMigration(m Migration, up bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %v", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	step, record := m.Down, `DELETE FROM schema_migrations WHERE version = ?`
+	args := []any{m.Version}
+	if up {
+		step, record = m.Up, `INSERT INTO schema_migrations (version, name, appliedAt) VALUES (?, ?, ?)`
+		args = append(args, m.Name, time.Now().Unix())
+	}
+
+	if err := step(tx, s.dialect); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(s.rebind(record), args...); err != nil {
+		return fmt.Errorf("failed to record migration %d: %v", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %v", m.Version, err)
+	}
+
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	log.Printf("Migrated %s: %d %s", direction, m.Version, m.Name)
+	return nil
+}
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Migrations in version order. Never edit a released migration, add a new one.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_matches_and_player_status",
+		// IF NOT EXISTS adopts databases created before migrations existed
+		Up: sqlMigration(`
+			CREATE TABLE IF NOT EXISTS matches (
+				id {{id}},
+				matchId TEXT UNIQUE,
+				gameId TEXT,
+				players TEXT
+			);
+			CREATE TABLE IF NOT EXISTS playerStatus (
+				id {{id}},
+				playerId TEXT UNIQUE,
+				status TEXT,
+				matchId TEXT
+			)
+		`),
+		Down: sqlMigration(`
+			DROP TABLE playerStatus;
+			DROP TABLE matches
+		`),
+	},
+	{
+		Version: 2,
+		Name:    "create_player_penalties",
+		Up: sqlMigration(`
+			CREATE TABLE IF NOT EXISTS playerPenalties (
+				id {{id}},
+				playerId TEXT UNIQUE,
+				abandons INTEGER NOT NULL DEFAULT 0,
+				dodges INTEGER NOT NULL DEFAULT 0,
+				points INTEGER NOT NULL DEFAULT 0,
+				lastOffenseAt {{bigint}} NOT NULL DEFAULT 0,
+				cooldownUntil {{bigint}} NOT NULL DEFAULT 0
+			)
+		`),
+		Down: sqlMigration(`
+			DROP TABLE playerPenalties
+		`),
+	},
+	{
+		Version: 3,
+		Name:    "normalize_match_players",
+		Up:      normalizeMatchPlayers,
+		Down:    denormalizeMatchPlayers,
+	},
+	{
+		Version: 4,
+		Name:    "add_match_timestamps",
+		Up: sqlMigration(`
+			ALTER TABLE matches ADD COLUMN createdAt {{bigint}} NOT NULL DEFAULT 0;
+			ALTER TABLE matches ADD COLUMN startedAt {{bigint}};
+			ALTER TABLE matches ADD COLUMN endedAt {{bigint}};
+			CREATE INDEX idx_matches_created ON matches (createdAt, id)
+		`),
+		Down: sqlMigration(`
+			DROP INDEX idx_matches_created;
+			ALTER TABLE matches DROP COLUMN endedAt;
+			ALTER TABLE matches DROP COLUMN startedAt;
+			ALTER TABLE matches DROP COLUMN createdAt
+		`),
+	},
+	{
+		Version: 5,
+		Name:    "create_player_ratings",
+		Up: sqlMigration(`
+			CREATE TABLE player_ratings (
+				id {{id}},
+				playerId TEXT NOT NULL,
+				gameId TEXT NOT NULL,
+				rating INTEGER NOT NULL,
+				games INTEGER NOT NULL DEFAULT 0,
+				UNIQUE (playerId, gameId)
+			);
+			CREATE INDEX idx_player_ratings_game ON player_ratings (gameId, rating);
+			CREATE INDEX idx_matches_game_ended ON matches (gameId, endedAt)
+		`),
+		Down: sqlMigration(`
+			DROP INDEX idx_matches_game_ended;
+			DROP TABLE player_ratings
+		`),
+	},
+	{
+		Version: 6,
+		Name:    "add_match_player_stats",
+		Up: sqlMigration(`
+			ALTER TABLE match_players ADD COLUMN maxLength INTEGER;
+			ALTER TABLE match_players ADD COLUMN foodEaten INTEGER;
+			ALTER TABLE match_players ADD COLUMN kills INTEGER;
+			ALTER TABLE match_players ADD COLUMN timeAliveMs {{bigint}}
+		`),
+		Down: sqlMigration(`
+			ALTER TABLE match_players DROP COLUMN timeAliveMs;
+			ALTER TABLE match_players DROP COLUMN kills;
+			ALTER TABLE match_players DROP COLUMN foodEaten;
+			ALTER TABLE match_players DROP COLUMN maxLength
+		`),
+	},
+	{
+		Version: 7,
+		Name:    "create_player_achievements",
+		Up: sqlMigration(`
+			CREATE TABLE player_achievements (
+				id {{id}},
+				playerId TEXT NOT NULL,
+				achievementId TEXT NOT NULL,
+				progress INTEGER NOT NULL DEFAULT 0,
+				unlockedAt {{bigint}},
+				UNIQUE (playerId, achievementId)
+			)
+		`),
+		Down: sqlMigration(`
+			DROP TABLE player_achievements
+		`),
+	},
+	{
+		Version: 8,
+		Name:    "create_match_replays",
+		Up: sqlMigration(`
+			CREATE TABLE match_replays (
+				id {{id}},
+				matchId TEXT NOT NULL UNIQUE,
+				gameId TEXT NOT NULL,
+				data TEXT NOT NULL,
+				recordedAt {{bigint}} NOT NULL
+			)
+		`),
+		Down: sqlMigration(`
+			DROP TABLE match_replays
+		`),
+	},
+}
+
+func normalizeMatchPlayers(tx *sql.Tx, d dialect) error {
+	err := sqlMigration(`
+		CREATE TABLE match_players (
+			id {{id}},
+			matchId TEXT NOT NULL,
+			playerId TEXT NOT NULL,
+			seat INTEGER NOT NULL,
+			team INTEGER NOT NULL DEFAULT 0,
+			finalScore INTEGER,
+			placement INTEGER,
+			deathReason TEXT,
+			UNIQUE (matchId, playerId)
+		);
+		CREATE INDEX idx_match_players_player ON match_players (playerId)
+	`)(tx, d)
+	if err != nil {
+		return err
+	}
+
+	// Move the comma separated player lists into match_players
+	rows, err := tx.Query(`SELECT matchId, players FROM matches`)
+	if err != nil {
+		return err
+	}
+	playerLists := make(map[string]string)
+	for rows.Next() {
+		var matchId string
+		var playerList sql.NullString
+		if err := rows.Scan(&matchId, &playerList); err != nil {
+			rows.Close()
+			return err
+		}
+		playerLists[matchId] = playerList.String
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for matchId, playerList := range playerLists {
+		for seat, playerId := range strings.Split(playerList, ",") {
+			playerId = strings.TrimSpace(playerId)
+			if playerId == "" {
+				continue
+			}
+			_, err := tx.Exec(rebind(`
+				INSERT INTO match_players (matchId, playerId, seat)
+				VALUES (?, ?, ?)
+			`, d), matchId, playerId, seat)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(`ALTER TABLE matches DROP COLUMN players`)
+	return err
+}
+
+func denormalizeMatchPlayers(tx *sql.Tx, d dialect) error {
+	if _, err := tx.Exec(`ALTER TABLE matches ADD COLUMN players TEXT`); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT matchId, playerId FROM match_players ORDER BY matchId, seat`)
+	if err != nil {
+		return err
+	}
+	players := make(map[string][]string)
+	for rows.Next() {
+		var matchId, playerId string
+		if err := rows.Scan(&matchId, &playerId); err != nil {
+			rows.Close()
+			return err
+		}
+		players[matchId] = append(players[matchId], playerId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for matchId, playerIds := range players {
+		_, err := tx.Exec(rebind(`UPDATE matches SET players = ? WHERE matchId = ?`, d), strings.Join(playerIds, ","), matchId)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`DROP TABLE match_players`)
+	return err
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// NewPostgresStore connects to the database described by dsn. Call
+// PrepareSchema or MigrateTo before use.
+func NewPostgresStore(dsn string) (*SQLStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to DB: %v", err)
+	}
+
+	return &SQLStore{
+		db:      db,
+		dialect: dialectPostgres,
+	}, nil
+}
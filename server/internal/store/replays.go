@@ -0,0 +1,41 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func (s *SQLStore) SaveReplay(replay MatchReplay) error {
+	_, err := s.exec(`
+		INSERT INTO match_replays (matchId, gameId, data, recordedAt)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(matchId) DO UPDATE SET
+			gameId = excluded.gameId,
+			data = excluded.data,
+			recordedAt = excluded.recordedAt
+	`, replay.MatchId, replay.GameId, string(replay.Data), unixOrZero(replay.RecordedAt))
+
+	if err != nil {
+		return fmt.Errorf("failed to save replay: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) LoadReplay(matchId string) (*MatchReplay, error) {
+	replay := MatchReplay{MatchId: matchId}
+	var data string
+	var recordedAt int64
+	err := s.queryRow(`
+		SELECT gameId, data, recordedAt FROM match_replays WHERE matchId = ?
+	`, matchId).Scan(&replay.GameId, &data, &recordedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load replay: %v", err)
+	}
+	replay.Data = []byte(data)
+	replay.RecordedAt = timeOrZero(recordedAt)
+	return &replay, nil
+}
@@ -0,0 +1,439 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLStore implements Store on top of database/sql. Queries are written with
+// ? placeholders and rebound for drivers that use numbered parameters.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+func (s *SQLStore) rebind(query string) string {
+	return rebind(query, s.dialect)
+}
+
+func rebind(query string, d dialect) string {
+	if d != dialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *SQLStore) exec(query string, args ...any) (sql.Result, error) {
+	return s.db.Exec(s.rebind(query), args...)
+}
+
+func (s *SQLStore) query(query string, args ...any) (*sql.Rows, error) {
+	return s.db.Query(s.rebind(query), args...)
+}
+
+func (s *SQLStore) queryRow(query string, args ...any) *sql.Row {
+	return s.db.QueryRow(s.rebind(query), args...)
+}
+
+func (s *SQLStore) SaveMatch(match Match) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to insert match: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(s.rebind(`
+		INSERT INTO matches (matchId, gameId, createdAt)
+		VALUES (?, ?, ?)
+	`), match.MatchId, match.GameId, unixOrZero(match.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("failed to insert match: %v", err)
+	}
+
+	for seat, playerId := range match.Players {
+		_, err = tx.Exec(s.rebind(`
+			INSERT INTO match_players (matchId, playerId, seat)
+			VALUES (?, ?, ?)
+		`), match.MatchId, playerId, seat)
+		if err != nil {
+			return fmt.Errorf("failed to insert match player: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert match: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) LoadMatch(matchId string) (*Match, error) {
+	var gameId string
+	var createdAt int64
+	var endedAt sql.NullInt64
+	err := s.queryRow(`
+		SELECT gameId, createdAt, endedAt FROM matches WHERE matchId = ?
+	`, matchId).Scan(&gameId, &createdAt, &endedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load match: %v", err)
+	}
+
+	rows, err := s.query(`
+		SELECT playerId FROM match_players WHERE matchId = ? ORDER BY seat
+	`, matchId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load match players: %v", err)
+	}
+	defer rows.Close()
+
+	players := make([]string, 0)
+	for rows.Next() {
+		var playerId string
+		if err := rows.Scan(&playerId); err != nil {
+			return nil, fmt.Errorf("failed to scan match player: %v", err)
+		}
+		players = append(players, playerId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load match players: %v", err)
+	}
+
+	return &Match{
+		GameId:    gameId,
+		MatchId:   matchId,
+		Players:   players,
+		CreatedAt: timeOrZero(createdAt),
+		EndedAt:   timeOrZero(endedAt.Int64),
+	}, nil
+}
+
+func (s *SQLStore) ListMatches() ([]Match, error) {
+	rows, err := s.query(`
+		SELECT m.matchId, m.gameId, m.createdAt, mp.playerId
+		FROM matches m
+		LEFT JOIN match_players mp ON mp.matchId = m.matchId
+		ORDER BY m.id, mp.seat
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list matches: %v", err)
+	}
+	defer rows.Close()
+
+	matches := make([]Match, 0)
+	for rows.Next() {
+		var matchId, gameId string
+		var createdAt int64
+		var playerId sql.NullString
+		if err := rows.Scan(&matchId, &gameId, &createdAt, &playerId); err != nil {
+			return nil, fmt.Errorf("failed to scan match: %v", err)
+		}
+		if len(matches) == 0 || matches[len(matches)-1].MatchId != matchId {
+			matches = append(matches, Match{
+				MatchId:   matchId,
+				GameId:    gameId,
+				Players:   make([]string, 0),
+				CreatedAt: timeOrZero(createdAt),
+			})
+		}
+		if playerId.Valid {
+			last := &matches[len(matches)-1]
+			last.Players = append(last.Players, playerId.String)
+		}
+	}
+	return matches, rows.Err()
+}
+
+func (s *SQLStore) ListPlayerMatches(q PlayerMatchQuery) ([]PlayerMatch, error) {
+	query := `
+		SELECT m.id, m.matchId, m.gameId, m.createdAt, m.startedAt, m.endedAt,
+			mp.playerId, mp.seat, mp.team, mp.finalScore, mp.placement, mp.deathReason
+		FROM match_players mp
+		JOIN matches m ON m.matchId = mp.matchId
+		WHERE mp.playerId = ?`
+	args := []any{q.PlayerId}
+
+	if q.GameId != "" {
+		query += ` AND m.gameId = ?`
+		args = append(args, q.GameId)
+	}
+	if !q.From.IsZero() {
+		query += ` AND m.createdAt >= ?`
+		args = append(args, q.From.Unix())
+	}
+	if !q.To.IsZero() {
+		query += ` AND m.createdAt < ?`
+		args = append(args, q.To.Unix())
+	}
+	switch q.Result {
+	case ResultWin:
+		query += ` AND mp.placement = 1`
+	case ResultLoss:
+		query += ` AND mp.placement > 1`
+	case ResultUnfinished:
+		query += ` AND mp.placement IS NULL`
+	}
+	if q.After != nil {
+		query += ` AND (m.createdAt < ? OR (m.createdAt = ? AND m.id < ?))`
+		args = append(args, q.After.CreatedAt, q.After.CreatedAt, q.After.Seq)
+	}
+	query += ` ORDER BY m.createdAt DESC, m.id DESC LIMIT ?`
+	args = append(args, q.Limit)
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list player matches: %v", err)
+	}
+	defer rows.Close()
+
+	matches := make([]PlayerMatch, 0)
+	for rows.Next() {
+		var pm PlayerMatch
+		var startedAt, endedAt sql.NullInt64
+		dest := []any{&pm.Cursor.Seq, &pm.MatchId, &pm.GameId, &pm.Cursor.CreatedAt, &startedAt, &endedAt}
+		player, err := scanMatchPlayer(rows, dest...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan player match: %v", err)
+		}
+		pm.Player = *player
+		pm.CreatedAt = timeOrZero(pm.Cursor.CreatedAt)
+		pm.StartedAt = timeOrZero(startedAt.Int64)
+		pm.EndedAt = timeOrZero(endedAt.Int64)
+		pm.Opponents = make([]MatchPlayer, 0)
+		matches = append(matches, pm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list player matches: %v", err)
+	}
+
+	if err := s.loadOpponents(q.PlayerId, matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func (s *SQLStore) loadOpponents(playerId string, matches []PlayerMatch) error {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	byMatch := make(map[string]*PlayerMatch, len(matches))
+	args := []any{playerId}
+	for i := range matches {
+		byMatch[matches[i].MatchId] = &matches[i]
+		args = append(args, matches[i].MatchId)
+	}
+
+	rows, err := s.query(`
+		SELECT matchId, playerId, seat, team, finalScore, placement, deathReason
+		FROM match_players
+		WHERE playerId <> ? AND matchId IN (`+placeholders(len(matches))+`)
+		ORDER BY seat
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load opponents: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var matchId string
+		opponent, err := scanMatchPlayer(rows, &matchId)
+		if err != nil {
+			return fmt.Errorf("failed to scan opponent: %v", err)
+		}
+		pm := byMatch[matchId]
+		pm.Opponents = append(pm.Opponents, *opponent)
+	}
+	return rows.Err()
+}
+
+func (s *SQLStore) MarkMatchStarted(matchId string, at time.Time) error {
+	// Only the first start counts, reconnects restart the game loop
+	_, err := s.exec(`
+		UPDATE matches SET startedAt = ? WHERE matchId = ? AND startedAt IS NULL
+	`, at.Unix(), matchId)
+	if err != nil {
+		return fmt.Errorf("failed to mark match started: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) MarkMatchEnded(matchId string, at time.Time) error {
+	_, err := s.exec(`
+		UPDATE matches SET endedAt = ? WHERE matchId = ?
+	`, at.Unix(), matchId)
+	if err != nil {
+		return fmt.Errorf("failed to mark match ended: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetPlayerStatus(playerId string) (string, string, error) {
+	var status, matchId string
+	err := s.queryRow(`
+		SELECT status, matchId FROM playerStatus WHERE playerId = ?
+	`, playerId).Scan(&status, &matchId)
+
+	if err == sql.ErrNoRows {
+		return "", "", ErrNotFound
+	}
+	return status, matchId, err
+}
+
+func (s *SQLStore) UpdatePlayerStatus(playerId, status, matchId string) error {
+	_, err := s.exec(`
+		INSERT INTO playerStatus (playerId, status, matchId)
+		VALUES (?, ?, ?)
+		ON CONFLICT(playerId) DO UPDATE SET
+			status = excluded.status,
+			matchId = excluded.matchId
+	`, playerId, status, matchId)
+
+	if err != nil {
+		return fmt.Errorf("failed to update player status: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetPenalty(playerId string) (*PlayerPenalty, error) {
+	row := s.queryRow(`
+		SELECT playerId, abandons, dodges, points, lastOffenseAt, cooldownUntil
+		FROM playerPenalties WHERE playerId = ?
+	`, playerId)
+
+	penalty, err := scanPenalty(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return penalty, err
+}
+
+func (s *SQLStore) SavePenalty(p PlayerPenalty) error {
+	_, err := s.exec(`
+		INSERT INTO playerPenalties (playerId, abandons, dodges, points, lastOffenseAt, cooldownUntil)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(playerId) DO UPDATE SET
+			abandons = excluded.abandons,
+			dodges = excluded.dodges,
+			points = excluded.points,
+			lastOffenseAt = excluded.lastOffenseAt,
+			cooldownUntil = excluded.cooldownUntil
+	`, p.PlayerId, p.Abandons, p.Dodges, p.Points, unixOrZero(p.LastOffenseAt), unixOrZero(p.CooldownUntil))
+
+	if err != nil {
+		return fmt.Errorf("failed to save penalty: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) DeletePenalty(playerId string) error {
+	_, err := s.exec(`DELETE FROM playerPenalties WHERE playerId = ?`, playerId)
+	if err != nil {
+		return fmt.Errorf("failed to delete penalty: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListPenalties() ([]PlayerPenalty, error) {
+	rows, err := s.query(`
+		SELECT playerId, abandons, dodges, points, lastOffenseAt, cooldownUntil
+		FROM playerPenalties ORDER BY lastOffenseAt DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list penalties: %v", err)
+	}
+	defer rows.Close()
+
+	penalties := make([]PlayerPenalty, 0)
+	for rows.Next() {
+		penalty, err := scanPenalty(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan penalty: %v", err)
+		}
+		penalties = append(penalties, *penalty)
+	}
+	return penalties, rows.Err()
+}
+
+func (s *SQLStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPenalty(row rowScanner) (*PlayerPenalty, error) {
+	var p PlayerPenalty
+	var lastOffenseAt, cooldownUntil int64
+	err := row.Scan(&p.PlayerId, &p.Abandons, &p.Dodges, &p.Points, &lastOffenseAt, &cooldownUntil)
+	if err != nil {
+		return nil, err
+	}
+	p.LastOffenseAt = timeOrZero(lastOffenseAt)
+	p.CooldownUntil = timeOrZero(cooldownUntil)
+	return &p, nil
+}
+
+// scanMatchPlayer scans the leading columns into dest followed by playerId,
+// seat, team, finalScore, placement and deathReason
+func scanMatchPlayer(row rowScanner, dest ...any) (*MatchPlayer, error) {
+	var mp MatchPlayer
+	var finalScore, placement sql.NullInt64
+	var deathReason sql.NullString
+	dest = append(dest, &mp.PlayerId, &mp.Seat, &mp.Team, &finalScore, &placement, &deathReason)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	mp.FinalScore = intOrNil(finalScore)
+	mp.Placement = intOrNil(placement)
+	mp.DeathReason = deathReason.String
+	return &mp, nil
+}
+
+func intOrNil(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// testPostgres is an embedded Postgres shared by the tests of the package,
+// started by the first test that needs it
+var testPostgres struct {
+	once   sync.Once
+	server *embeddedpostgres.EmbeddedPostgres
+	dir    string
+	dsn    string
+	err    error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if testPostgres.server != nil {
+		testPostgres.server.Stop()
+	}
+	if testPostgres.dir != "" {
+		os.RemoveAll(testPostgres.dir)
+	}
+	os.Exit(code)
+}
+
+// testPostgresDSN returns TEST_POSTGRES_DSN when set, otherwise it starts the
+// embedded Postgres. Its binaries are downloaded once and cached.
+func testPostgresDSN() (string, error) {
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		return dsn, nil
+	}
+	testPostgres.once.Do(func() {
+		testPostgres.dsn, testPostgres.err = startTestPostgres()
+	})
+	return testPostgres.dsn, testPostgres.err
+}
+
+func startTestPostgres() (string, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return "", err
+	}
+	port := uint32(l.Addr().(*net.TCPAddr).Port)
+	l.Close()
+
+	dir, err := os.MkdirTemp("", "store-postgres-")
+	if err != nil {
+		return "", err
+	}
+	testPostgres.dir = dir
+
+	server := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(dir).
+		StartTimeout(time.Minute).
+		Logger(io.Discard))
+	if err := server.Start(); err != nil {
+		return "", fmt.Errorf("failed to start embedded postgres: %v", err)
+	}
+	testPostgres.server = server
+	return fmt.Sprintf("host=localhost port=%d user=postgres password=postgres dbname=postgres sslmode=disable", port), nil
+}
+
+// openTestPostgres connects to Postgres inside a schema of its own, which is
+// dropped when the test ends
+func openTestPostgres(t *testing.T) *SQLStore {
+	t.Helper()
+	dsn, err := testPostgresDSN()
+	if err != nil {
+		t.Skipf("no postgres to test against, set TEST_POSTGRES_DSN to use a running one: %v", err)
+	}
+
+	admin, err := NewPostgresStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.db.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	// lib/pq passes unknown settings on to the server
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	s, err := NewPostgresStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query    string
+		postgres string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = $1"},
+		{"INSERT INTO t (a, b, c) VALUES (?, ?, ?)", "INSERT INTO t (a, b, c) VALUES ($1, $2, $3)"},
+		{
+			"WHERE id <> ? AND matchId IN (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			"WHERE id <> $1 AND matchId IN ($2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.query, dialectSQLite); got != tt.query {
+			t.Errorf("sqlite rebind(%q) = %q, want it unchanged", tt.query, got)
+		}
+		if got := rebind(tt.query, dialectPostgres); got != tt.postgres {
+			t.Errorf("postgres rebind(%q) = %q, want %q", tt.query, got, tt.postgres)
+		}
+	}
+}
+
+func TestExpandTypes(t *testing.T) {
+	query := "CREATE TABLE t (id {{id}}, at {{bigint}} NOT NULL, n {{bigint}})"
+	tests := []struct {
+		dialect dialect
+		want    string
+	}{
+		{dialectSQLite, "CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, at INTEGER NOT NULL, n INTEGER)"},
+		{dialectPostgres, "CREATE TABLE t (id SERIAL PRIMARY KEY, at BIGINT NOT NULL, n BIGINT)"},
+	}
+	for _, tt := range tests {
+		if got := expandTypes(query, tt.dialect); got != tt.want {
+			t.Errorf("expandTypes for dialect %d = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+// forEachSQLStore runs a test against the SQL backends, before any migration
+func forEachSQLStore(t *testing.T, test func(t *testing.T, s *SQLStore)) {
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "matches.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(t, s)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, openTestPostgres(t))
+	})
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	forEachSQLStore(t, func(t *testing.T, s *SQLStore) {
+		if version, err := s.SchemaVersion(); err != nil || version != 0 {
+			t.Fatalf("SchemaVersion of an empty database = %d, %v, want 0", version, err)
+		}
+		if err := PrepareSchema(s); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveMatch(Match{GameId: "snake", MatchId: "m1", Players: []string{"b", "a", "c"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SavePenalty(PlayerPenalty{PlayerId: "a", Dodges: 1, Points: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		// back to players as a list, to before the penalties, and forward again
+		for _, target := range []int{2, LatestSchemaVersion(), 1, LatestSchemaVersion()} {
+			if err := s.MigrateTo(target); err != nil {
+				t.Fatal(err)
+			}
+			if version, err := s.SchemaVersion(); err != nil || version != target {
+				t.Fatalf("SchemaVersion = %d, %v, want %d", version, err, target)
+			}
+		}
+		m, err := s.LoadMatch("m1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(m.Players, ",") != "b,a,c" {
+			t.Fatalf("players after migrating down and up = %v", m.Players)
+		}
+		if _, err := s.GetPenalty("a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetPenalty after recreating the table: got %v, want ErrNotFound", err)
+		}
+
+		if err := s.MigrateTo(0); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.MigrateTo(LatestSchemaVersion() + 1); err == nil {
+			t.Fatal("migrating past the latest version succeeded")
+		}
+	})
+}
+
+func TestPrepareSchemaRefusesNewerDatabase(t *testing.T) {
+	forEachSQLStore(t, func(t *testing.T, s *SQLStore) {
+		if err := PrepareSchema(s); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.exec(`INSERT INTO schema_migrations (version, name, appliedAt) VALUES (?, ?, ?)`, LatestSchemaVersion()+1, "from_the_future", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := PrepareSchema(s); !errors.Is(err, ErrSchemaTooNew) {
+			t.Fatalf("PrepareSchema = %v, want ErrSchemaTooNew", err)
+		}
+		if err := s.MigrateTo(1); !errors.Is(err, ErrSchemaTooNew) {
+			t.Fatalf("MigrateTo = %v, want ErrSchemaTooNew", err)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// NewSQLiteStore opens the database at path. Call PrepareSchema or MigrateTo
+// before use.
+func NewSQLiteStore(path string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %v", err)
+	}
+
+	return &SQLStore{
+		db:      db,
+		dialect: dialectSQLite,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func (s *SQLStore) SnakeStats(playerId string, gameIds []string) (*SnakeStats, error) {
+	stats := &SnakeStats{
+		PlayerId:      playerId,
+		DeathsByCause: make(map[string]int),
+	}
+	if len(gameIds) == 0 {
+		return stats, nil
+	}
+
+	// Only finished matches have a placement
+	finished := `
+		FROM match_players mp
+		JOIN matches m ON m.matchId = mp.matchId
+		WHERE mp.playerId = ? AND mp.placement IS NOT NULL
+			AND m.gameId IN (` + placeholders(len(gameIds)) + `)`
+	args := []any{playerId}
+	for _, gameId := range gameIds {
+		args = append(args, gameId)
+	}
+
+	var averageScore sql.NullFloat64
+	var bestScore, longestLength, foodEaten, kills, timeAliveMs sql.NullInt64
+	err := s.queryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN mp.placement = 1 THEN 1 ELSE 0 END), 0),
+			AVG(mp.finalScore), MAX(mp.finalScore), MAX(mp.maxLength),
+			SUM(mp.foodEaten), SUM(mp.kills), SUM(mp.timeAliveMs)
+	`+finished, args...).Scan(&stats.GamesPlayed, &stats.Wins,
+		&averageScore, &bestScore, &longestLength, &foodEaten, &kills, &timeAliveMs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snake stats: %v", err)
+	}
+	stats.AverageScore = averageScore.Float64
+	stats.BestScore = int(bestScore.Int64)
+	stats.LongestLength = int(longestLength.Int64)
+	stats.FoodEaten = int(foodEaten.Int64)
+	stats.Kills = int(kills.Int64)
+	stats.TimeAliveSeconds = timeAliveMs.Int64 / 1000
+
+	rows, err := s.query(`
+		SELECT mp.deathReason, COUNT(*)
+	`+finished+` AND mp.deathReason IS NOT NULL AND mp.deathReason <> ''
+		GROUP BY mp.deathReason
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deaths by cause: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cause string
+		var count int
+		if err := rows.Scan(&cause, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan death cause: %v", err)
+		}
+		stats.DeathsByCause[cause] = count
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+const (
+	ResultWin        = "win"
+	ResultLoss       = "loss"
+	ResultUnfinished = "unfinished"
+)
+
+type Match struct {
+	GameId    string    `json:"gameId"`
+	MatchId   string    `json:"matchId"`
+	Players   []string  `json:"players"`
+	CreatedAt time.Time `json:"createdAt"`
+	// EndedAt is filled in by LoadMatch once the match has finished
+	EndedAt time.Time `json:"endedAt,omitzero"`
+}
+
+// MatchPlayer is one player's seat and result in a match. Result fields stay
+// nil until the match has finished.
+type MatchPlayer struct {
+	PlayerId    string `json:"playerId"`
+	Seat        int    `json:"seat"`
+	Team        int    `json:"team"`
+	FinalScore  *int   `json:"finalScore"`
+	Placement   *int   `json:"placement"`
+	DeathReason string `json:"deathReason,omitempty"`
+}
+
+// Result is win for first place, loss for any other placement
+func (mp MatchPlayer) Result() string {
+	switch {
+	case mp.Placement == nil:
+		return ResultUnfinished
+	case *mp.Placement == 1:
+		return ResultWin
+	default:
+		return ResultLoss
+	}
+}
+
+// PlayerMatch is a match seen from one player's side
+type PlayerMatch struct {
+	MatchId   string
+	GameId    string
+	CreatedAt time.Time
+	StartedAt time.Time
+	EndedAt   time.Time
+	Player    MatchPlayer
+	Opponents []MatchPlayer
+	Cursor    MatchCursor
+}
+
+// MatchCursor is the position of a match in newest-first history order
+type MatchCursor struct {
+	CreatedAt int64
+	Seq       int64
+}
+
+// PlayerMatchQuery filters a player's match history. Zero values are ignored.
+type PlayerMatchQuery struct {
+	PlayerId string
+	GameId   string
+	// From is inclusive, To is exclusive
+	From   time.Time
+	To     time.Time
+	Result string
+	// Only matches strictly older than After are returned
+	After *MatchCursor
+	Limit int
+}
+
+// PlayerResult is a player's outcome when a match ends. Placement is nil for
+// a player that never joined, who then counts as not having played.
+type PlayerResult struct {
+	PlayerId    string
+	FinalScore  int
+	Placement   *int
+	DeathReason string
+	MaxLength   int
+	FoodEaten   int
+	Kills       int
+	TimeAlive   time.Duration
+}
+
+// SnakeStats aggregates a player's finished snake matches
+type SnakeStats struct {
+	PlayerId         string         `json:"playerId"`
+	GamesPlayed      int            `json:"gamesPlayed"`
+	Wins             int            `json:"wins"`
+	AverageScore     float64        `json:"averageScore"`
+	BestScore        int            `json:"bestScore"`
+	LongestLength    int            `json:"longestLength"`
+	FoodEaten        int            `json:"foodEaten"`
+	Kills            int            `json:"kills"`
+	TimeAliveSeconds int64          `json:"timeAliveSeconds"`
+	DeathsByCause    map[string]int `json:"deathsByCause"`
+}
+
+type Rating struct {
+	PlayerId string
+	GameId   string
+	Rating   int
+	Games    int
+}
+
+const (
+	MetricScore  = "score"
+	MetricWins   = "wins"
+	MetricRating = "rating"
+)
+
+// LeaderboardQuery ranks players of one game. Since limits score and wins to
+// matches ended after it and rating to players who played after it.
+type LeaderboardQuery struct {
+	GameId   string
+	Metric   string
+	Since    time.Time
+	Limit    int
+	PlayerId string
+}
+
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	PlayerId string `json:"playerId"`
+	Value    int    `json:"value"`
+}
+
+// PlayerAchievement is a player's progress towards one achievement
+type PlayerAchievement struct {
+	PlayerId      string
+	AchievementId string
+	Progress      int
+	// UnlockedAt stays zero until the achievement is unlocked
+	UnlockedAt time.Time
+}
+
+// MatchReplay is the recording of a finished match. The game encodes Data,
+// the store keeps it as it is.
+type MatchReplay struct {
+	MatchId    string
+	GameId     string
+	Data       []byte
+	RecordedAt time.Time
+}
+
+type PlayerPenalty struct {
+	PlayerId      string    `json:"playerId"`
+	Abandons      int       `json:"abandons"`
+	Dodges        int       `json:"dodges"`
+	Points        int       `json:"points"`
+	LastOffenseAt time.Time `json:"lastOffenseAt"`
+	CooldownUntil time.Time `json:"cooldownUntil"`
+}
+
+// MatchStore persists created matches and each player's matchmaking status
+type MatchStore interface {
+	SaveMatch(match Match) error
+	// LoadMatch returns ErrNotFound if the match does not exist
+	LoadMatch(matchId string) (*Match, error)
+	ListMatches() ([]Match, error)
+	// ListPlayerMatches returns a player's matches newest first
+	ListPlayerMatches(query PlayerMatchQuery) ([]PlayerMatch, error)
+	MarkMatchStarted(matchId string, at time.Time) error
+	MarkMatchEnded(matchId string, at time.Time) error
+	// GetPlayerStatus returns ErrNotFound if the player has never been queued
+	GetPlayerStatus(playerId string) (status string, matchId string, err error)
+	UpdatePlayerStatus(playerId, status, matchId string) error
+}
+
+// PenaltyStore persists queue penalty records
+type PenaltyStore interface {
+	// GetPenalty returns ErrNotFound if the player has no penalty record
+	GetPenalty(playerId string) (*PlayerPenalty, error)
+	SavePenalty(penalty PlayerPenalty) error
+	DeletePenalty(playerId string) error
+	ListPenalties() ([]PlayerPenalty, error)
+}
+
+// LeaderboardStore persists match results and per-game ratings
+type LeaderboardStore interface {
+	SaveMatchResults(matchId string, results []PlayerResult) error
+	// GetRatings returns stored ratings, players without one are left out
+	GetRatings(gameId string, playerIds []string) (map[string]Rating, error)
+	SaveRatings(ratings []Rating) error
+	// Leaderboard returns the top Limit entries plus PlayerId's entry if it ranks lower
+	Leaderboard(query LeaderboardQuery) ([]LeaderboardEntry, error)
+}
+
+// StatsStore aggregates per-player statistics from saved match results
+type StatsStore interface {
+	SnakeStats(playerId string, gameIds []string) (*SnakeStats, error)
+}
+
+// AchievementStore persists achievement progress and unlocks
+type AchievementStore interface {
+	// ListAchievements returns every achievement the player has progress on
+	ListAchievements(playerId string) ([]PlayerAchievement, error)
+	SaveAchievement(achievement PlayerAchievement) error
+}
+
+// ReplayStore persists the recordings of finished matches
+type ReplayStore interface {
+	SaveReplay(replay MatchReplay) error
+	// LoadReplay returns ErrNotFound if the match has no replay
+	LoadReplay(matchId string) (*MatchReplay, error)
+}
+
+// Store is the full persistence layer shared by the game server services
+type Store interface {
+	MatchStore
+	PenaltyStore
+	LeaderboardStore
+	StatsStore
+	AchievementStore
+	ReplayStore
+	Close() error
+}
@@ -0,0 +1,365 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// forEachStore runs a test against every backend. The SQL backends keep
+// seconds, so tests use whole-second times.
+func forEachStore(t *testing.T, test func(t *testing.T, s Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "matches.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		if err := PrepareSchema(s); err != nil {
+			t.Fatal(err)
+		}
+		test(t, s)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		s := openTestPostgres(t)
+		if err := PrepareSchema(s); err != nil {
+			t.Fatal(err)
+		}
+		test(t, s)
+	})
+}
+
+func at(sec int64) time.Time {
+	return time.Unix(1_700_000_000+sec, 0)
+}
+
+func placement(n int) *int {
+	return &n
+}
+
+func saveMatch(t *testing.T, s Store, matchId string, createdAt time.Time, players ...string) {
+	t.Helper()
+	if err := s.SaveMatch(Match{GameId: "snake", MatchId: matchId, Players: players, CreatedAt: createdAt}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreMatches(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		if _, err := s.LoadMatch("m1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("LoadMatch of unknown match: got %v, want ErrNotFound", err)
+		}
+
+		saveMatch(t, s, "m1", at(0), "b", "a")
+		saveMatch(t, s, "m2", at(10), "c")
+		if err := s.SaveMatch(Match{GameId: "snake", MatchId: "m1", Players: []string{"x"}}); err == nil {
+			t.Fatal("saving a match twice succeeded")
+		}
+
+		m, err := s.LoadMatch("m1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.GameId != "snake" || !slices.Equal(m.Players, []string{"b", "a"}) || !m.CreatedAt.Equal(at(0)) || !m.EndedAt.IsZero() {
+			t.Fatalf("LoadMatch = %+v", m)
+		}
+		if err := s.MarkMatchEnded("m1", at(60)); err != nil {
+			t.Fatal(err)
+		}
+		if m, err := s.LoadMatch("m1"); err != nil || !m.EndedAt.Equal(at(60)) {
+			t.Fatalf("LoadMatch of a finished match = %+v, %v", m, err)
+		}
+
+		matches, err := s.ListMatches()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(matches))
+		for _, m := range matches {
+			ids = append(ids, m.MatchId)
+		}
+		if !slices.Equal(ids, []string{"m1", "m2"}) || !slices.Equal(matches[0].Players, []string{"b", "a"}) {
+			t.Fatalf("ListMatches = %+v", matches)
+		}
+	})
+}
+
+func TestStorePlayerMatches(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		saveMatch(t, s, "m1", at(0), "a", "b")
+		saveMatch(t, s, "m2", at(10), "a", "c")
+		saveMatch(t, s, "m3", at(10), "c", "a")
+		saveMatch(t, s, "other", at(20), "b", "c")
+		if err := s.MarkMatchStarted("m1", at(1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.MarkMatchEnded("m1", at(60)); err != nil {
+			t.Fatal(err)
+		}
+		err := s.SaveMatchResults("m1", []PlayerResult{
+			{PlayerId: "a", FinalScore: 5, Placement: placement(1)},
+			{PlayerId: "b", FinalScore: 3, Placement: placement(2), DeathReason: "Hit wall"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page, err := s.ListPlayerMatches(PlayerMatchQuery{PlayerId: "a", Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// matches created in the same second come newest saved first
+		if len(page) != 2 || page[0].MatchId != "m3" || page[1].MatchId != "m2" {
+			t.Fatalf("first page = %+v", page)
+		}
+		if len(page[0].Opponents) != 1 || page[0].Opponents[0].PlayerId != "c" || page[0].Player.Seat != 1 {
+			t.Fatalf("m3 seen from a = %+v", page[0])
+		}
+
+		rest, err := s.ListPlayerMatches(PlayerMatchQuery{PlayerId: "a", After: &page[1].Cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rest) != 1 || rest[0].MatchId != "m1" {
+			t.Fatalf("second page = %+v", rest)
+		}
+		m1 := rest[0]
+		if !m1.StartedAt.Equal(at(1)) || !m1.EndedAt.Equal(at(60)) {
+			t.Fatalf("m1 seen from a = %+v", m1)
+		}
+		if m1.Player.Result() != ResultWin || *m1.Player.FinalScore != 5 || m1.Opponents[0].DeathReason != "Hit wall" {
+			t.Fatalf("m1 seen from a = %+v", m1)
+		}
+
+		unfinished, err := s.ListPlayerMatches(PlayerMatchQuery{PlayerId: "a", Result: ResultUnfinished, From: at(5), Limit: 10})
+		if err != nil || len(unfinished) != 2 {
+			t.Fatalf("unfinished since 5s = %+v, %v", unfinished, err)
+		}
+		before, err := s.ListPlayerMatches(PlayerMatchQuery{PlayerId: "a", To: at(10), Limit: 10})
+		if err != nil || len(before) != 1 || before[0].MatchId != "m1" {
+			t.Fatalf("matches before 10s = %+v, %v", before, err)
+		}
+		wins, err := s.ListPlayerMatches(PlayerMatchQuery{PlayerId: "a", Result: ResultWin, Limit: 10})
+		if err != nil || len(wins) != 1 || wins[0].MatchId != "m1" {
+			t.Fatalf("wins = %+v, %v", wins, err)
+		}
+	})
+}
+
+func TestStoreLeaderboard(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		results := map[string][]PlayerResult{
+			"m1": {{PlayerId: "a", FinalScore: 4, Placement: placement(1)}, {PlayerId: "b", FinalScore: 9, Placement: placement(2)}},
+			"m2": {{PlayerId: "a", FinalScore: 2, Placement: placement(1)}, {PlayerId: "c", FinalScore: 1, Placement: placement(2)}},
+		}
+		for i, matchId := range []string{"m1", "m2"} {
+			var players []string
+			for _, r := range results[matchId] {
+				players = append(players, r.PlayerId)
+			}
+			saveMatch(t, s, matchId, at(int64(i)), players...)
+			if err := s.SaveMatchResults(matchId, results[matchId]); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.MarkMatchEnded(matchId, at(int64(100*(i+1)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		wins, err := s.Leaderboard(LeaderboardQuery{GameId: "snake", Metric: MetricWins, Limit: 1, PlayerId: "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the asking player is added below the top entries, sharing b's rank
+		if !slices.Equal(wins, []LeaderboardEntry{{Rank: 1, PlayerId: "a", Value: 2}, {Rank: 2, PlayerId: "c", Value: 0}}) {
+			t.Fatalf("wins = %+v", wins)
+		}
+
+		scores, err := s.Leaderboard(LeaderboardQuery{GameId: "snake", Metric: MetricScore, Since: at(150), Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(scores, []LeaderboardEntry{{Rank: 1, PlayerId: "a", Value: 2}, {Rank: 2, PlayerId: "c", Value: 1}}) {
+			t.Fatalf("scores since 150s = %+v", scores)
+		}
+
+		if err := s.SaveRatings([]Rating{{PlayerId: "a", GameId: "snake", Rating: 1216, Games: 2}}); err != nil {
+			t.Fatal(err)
+		}
+		ratings, err := s.GetRatings("snake", []string{"a", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ratings) != 1 || ratings["a"].Rating != 1216 || ratings["a"].Games != 2 {
+			t.Fatalf("GetRatings = %+v", ratings)
+		}
+	})
+}
+
+func TestStoreSnakeStats(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		saveMatch(t, s, "m1", at(0), "a", "b")
+		saveMatch(t, s, "m2", at(10), "b", "a")
+		saveMatch(t, s, "unfinished", at(20), "a", "b")
+		saveMatch(t, s, "absent", at(30), "a", "b")
+		if err := s.SaveMatch(Match{GameId: "pong", MatchId: "p1", Players: []string{"a"}}); err != nil {
+			t.Fatal(err)
+		}
+		results := map[string][]PlayerResult{
+			"m1": {
+				{PlayerId: "a", FinalScore: 5, Placement: placement(1), MaxLength: 7, FoodEaten: 3, Kills: 1, TimeAlive: 30 * time.Second},
+				{PlayerId: "b", FinalScore: 1, Placement: placement(2), DeathReason: "Hit snake", MaxLength: 3, FoodEaten: 1, TimeAlive: 20 * time.Second},
+			},
+			"m2": {
+				{PlayerId: "b", FinalScore: 4, Placement: placement(1), MaxLength: 5, FoodEaten: 2, TimeAlive: 15 * time.Second},
+				{PlayerId: "a", FinalScore: 2, Placement: placement(2), DeathReason: "Hit wall", MaxLength: 4, FoodEaten: 1, TimeAlive: 12500 * time.Millisecond},
+			},
+			"p1": {{PlayerId: "a", FinalScore: 50, Placement: placement(1), MaxLength: 50}},
+			// a never joined, so the match doesn't count for it
+			"absent": {
+				{PlayerId: "b", FinalScore: 3, Placement: placement(1), MaxLength: 4, TimeAlive: 10 * time.Second},
+				{PlayerId: "a", DeathReason: "Never joined"},
+			},
+		}
+		for matchId, r := range results {
+			if err := s.SaveMatchResults(matchId, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stats, err := s.SnakeStats("a", []string{"snake"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := SnakeStats{
+			PlayerId:         "a",
+			DeathsByCause:    map[string]int{"Hit wall": 1},
+			GamesPlayed:      2,
+			Wins:             1,
+			AverageScore:     3.5,
+			BestScore:        5,
+			LongestLength:    7,
+			FoodEaten:        4,
+			Kills:            1,
+			TimeAliveSeconds: 42,
+		}
+		if !reflect.DeepEqual(*stats, want) {
+			t.Fatalf("SnakeStats = %+v, want %+v", *stats, want)
+		}
+
+		none, err := s.SnakeStats("nobody", []string{"snake"})
+		if err != nil || none.GamesPlayed != 0 || none.AverageScore != 0 || none.DeathsByCause == nil {
+			t.Fatalf("SnakeStats of a new player = %+v, %v", none, err)
+		}
+	})
+}
+
+func TestStoreAchievements(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		for _, a := range []PlayerAchievement{
+			{PlayerId: "a", AchievementId: "z-last", Progress: 1},
+			{PlayerId: "a", AchievementId: "a-first", Progress: 3, UnlockedAt: at(5)},
+			{PlayerId: "a", AchievementId: "z-last", Progress: 2},
+		} {
+			if err := s.SaveAchievement(a); err != nil {
+				t.Fatal(err)
+			}
+		}
+		achievements, err := s.ListAchievements("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(achievements) != 2 || achievements[0].AchievementId != "a-first" || !achievements[0].UnlockedAt.Equal(at(5)) ||
+			achievements[1].Progress != 2 || !achievements[1].UnlockedAt.IsZero() {
+			t.Fatalf("ListAchievements = %+v", achievements)
+		}
+	})
+}
+
+func TestStoreReplays(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		if _, err := s.LoadReplay("m1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("LoadReplay of unknown match: got %v, want ErrNotFound", err)
+		}
+		// saving again replaces the recording
+		for _, data := range []string{`{"v":1}`, `{"v":2}`} {
+			if err := s.SaveReplay(MatchReplay{MatchId: "m1", GameId: "snake", Data: []byte(data), RecordedAt: at(9)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		replay, err := s.LoadReplay("m1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(replay.Data) != `{"v":2}` || replay.GameId != "snake" || !replay.RecordedAt.Equal(at(9)) {
+			t.Fatalf("LoadReplay = %+v", replay)
+		}
+	})
+}
+
+func TestStorePlayerStatus(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		if _, _, err := s.GetPlayerStatus("a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetPlayerStatus of unknown player: got %v, want ErrNotFound", err)
+		}
+		if err := s.UpdatePlayerStatus("a", "queued", ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpdatePlayerStatus("a", "in_match", "m1"); err != nil {
+			t.Fatal(err)
+		}
+		status, matchId, err := s.GetPlayerStatus("a")
+		if err != nil || status != "in_match" || matchId != "m1" {
+			t.Fatalf("GetPlayerStatus = %q, %q, %v", status, matchId, err)
+		}
+	})
+}
+
+func TestStorePenalties(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		if _, err := s.GetPenalty("a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetPenalty of unknown player: got %v, want ErrNotFound", err)
+		}
+
+		older := PlayerPenalty{PlayerId: "a", Dodges: 1, Points: 1, LastOffenseAt: at(0)}
+		newer := PlayerPenalty{PlayerId: "b", Abandons: 2, Points: 4, LastOffenseAt: at(10), CooldownUntil: at(70)}
+		for _, p := range []PlayerPenalty{older, newer} {
+			if err := s.SavePenalty(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		older.Points = 2
+		if err := s.SavePenalty(older); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := s.GetPenalty("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Points != 2 || p.Dodges != 1 || !p.LastOffenseAt.Equal(at(0)) || !p.CooldownUntil.IsZero() {
+			t.Fatalf("GetPenalty = %+v", p)
+		}
+
+		penalties, err := s.ListPenalties()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(penalties) != 2 || penalties[0].PlayerId != "b" || !penalties[0].CooldownUntil.Equal(at(70)) {
+			t.Fatalf("ListPenalties = %+v, want b first", penalties)
+		}
+
+		if err := s.DeletePenalty("a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetPenalty("a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetPenalty after delete: got %v, want ErrNotFound", err)
+		}
+	})
+}
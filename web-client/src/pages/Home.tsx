@@ -28,6 +28,9 @@ const Home = () => {
   const [isQueued, setIsQueued] = useState<boolean>(false);
   const [loading, setLoading] = useState<boolean>(false);
   const [error, setError] = useState<string | null>(null);
+  // The match on offer until every player accepted it
+  const [offer, setOffer] = useState<GameEnv | null>(null);
+  const [accepted, setAccepted] = useState<boolean>(false);
   const pollRef = useRef<number | null>(null);
 
   const rootUrl = "http://localhost:8080/api";
@@ -89,7 +92,9 @@ const Home = () => {
 
       if (response.status === HttpStatusCode.Ok) {
         const data = response.data;
-        if (data.isFound && data.match) {
+        if (data.isFound && data.match?.status === "offered") {
+          setOffer(data.match.gameEnv);
+        } else if (data.isFound && data.match) {
           console.log(`✅ Match found: ${data.match.gameEnv.matchId}`);
           const newPlayer: Player = {
             username: player.username,
@@ -113,6 +118,11 @@ const Home = () => {
         }
       }
     } catch (err: any) {
+      // Without a match or offer the server answers with an error. An offer
+      // someone declined or let time out is gone, the server put us back in
+      // the queue unless we were the one.
+      setOffer(null);
+      setAccepted(false);
       console.error("Error checking match:", err);
     }
   };
@@ -135,6 +145,28 @@ const Home = () => {
     }
   };
 
+  /** Accept or decline the match on offer */
+  const answerOffer = async (answer: "accept" | "decline") => {
+    if (!player?.userId) return;
+    setLoading(true);
+    try {
+      await axios.post(`${rootUrl}/match-offer/${player.userId}/${answer}`);
+      if (answer === "accept") {
+        setAccepted(true);
+      } else {
+        // Declining counts as a dodge and takes us out of the queue
+        stopPolling();
+        setOffer(null);
+        setIsQueued(false);
+      }
+    } catch (err: any) {
+      console.error(`Match offer ${answer} error:`, err);
+      setError(err?.response?.data?.error ?? err.message ?? `Failed to ${answer} the match`);
+    } finally {
+      setLoading(false);
+    }
+  };
+
   /**  Add or remove queue button handler */
   const findMatchButton = () => {
     if (isQueued) removeQueue();
@@ -204,6 +236,34 @@ const Home = () => {
         )}
       </div>
 
+      {offer && (
+        <div className="bg-gray-800 shadow-md rounded-lg p-4 mt-6 w-full max-w-md text-center">
+          {accepted ? (
+            <p>Waiting for the other players to accept...</p>
+          ) : (
+            <>
+              <p className="mb-3">Match found for {offer.gameId}, accept within 20 seconds</p>
+              <div className="flex justify-center gap-4">
+                <button
+                  onClick={() => answerOffer("accept")}
+                  disabled={loading}
+                  className="bg-green-600 hover:bg-green-700 text-white font-semibold py-2 px-6 rounded-lg shadow-md transition-colors disabled:opacity-50"
+                >
+                  Accept
+                </button>
+                <button
+                  onClick={() => answerOffer("decline")}
+                  disabled={loading}
+                  className="bg-red-600 hover:bg-red-700 text-white font-semibold py-2 px-6 rounded-lg shadow-md transition-colors disabled:opacity-50"
+                >
+                  Decline
+                </button>
+              </div>
+            </>
+          )}
+        </div>
+      )}
+
       {error && <p className="text-red-400 mt-4">{error}</p>}
 
       <div className="mt-6">
@@ -3,6 +3,16 @@ import { useNavigate, useParams } from "react-router-dom";
 // import PlayerContext from "../../context/PlayerContext";
 
 const CELL_SIZE = 16;
+// Must match the server's PROTOCOL_VERSION, see GET /api/game/snake/protocol
+const PROTOCOL_VERSION = 1;
+// Close code of a connection taken over by a newer one, e.g. in another tab
+const CLOSE_SESSION_REPLACED = 4001;
+// Close codes of joins the server turns away
+const CLOSE_FORBIDDEN = 4003;
+const CLOSE_MATCH_NOT_FOUND = 4004;
+
+// The resume token lets a dropped connection take its snake back
+const resumeTokenKey = (matchId: string) => `snake-resume-token:${matchId}`;
 const BOARD_WIDTH = 60;
 const BOARD_HEIGHT = 40;
 
@@ -16,6 +26,9 @@ interface Score {
 }
 
 interface Snake {
+  playerId: string;
+  name: string;
+  color: string;
   snakeHead: Point;
   snakeBody: Point[];
   direction: string;
@@ -41,23 +54,125 @@ interface GameState {
   obstacles: Obstacle[];
 }
 
+interface SnakeDelta {
+  playerId: string;
+  head?: Point;
+  tailRemoved?: number;
+  direction?: string;
+  score?: number;
+  died?: boolean;
+  deathReason?: string;
+}
+
+interface BoardDelta {
+  tick: number;
+  full?: Snake[];
+  snakes?: SnakeDelta[];
+  removed?: string[];
+  foodsAdded?: Food[];
+  foodsRemoved?: Food[];
+}
+
+const applySnakeDelta = (snake: Snake, delta: SnakeDelta): Snake => {
+  let snakeBody = snake.snakeBody ?? [];
+  let snakeHead = snake.snakeHead;
+  if (delta.head) {
+    // The old head becomes the newest body segment
+    snakeBody = [...snakeBody, snakeHead].slice(delta.tailRemoved ?? 0);
+    snakeHead = delta.head;
+  }
+  return {
+    ...snake,
+    snakeHead,
+    snakeBody,
+    direction: delta.direction ?? snake.direction,
+    score: delta.score !== undefined ? { value: delta.score } : snake.score,
+    isAlive: delta.died ? false : snake.isAlive,
+  };
+};
+
+const sameFood = (a: Food, b: Food) =>
+  a.position.x === b.position.x && a.position.y === b.position.y && a.value === b.value;
+
+// applyDelta moves a snapshot forward by one tick
+const applyDelta = (state: GameState, delta: BoardDelta): GameState => {
+  const snakes = new Map<string, Snake>();
+  [state.playerSnake, ...(state.otherSnakes ?? [])].forEach((snake) => {
+    if (snake?.playerId) snakes.set(snake.playerId, snake);
+  });
+
+  delta.full?.forEach((snake) => snakes.set(snake.playerId, snake));
+  delta.snakes?.forEach((d) => {
+    const snake = snakes.get(d.playerId);
+    if (snake) snakes.set(d.playerId, applySnakeDelta(snake, d));
+  });
+  delta.removed?.forEach((playerId) => {
+    const snake = snakes.get(playerId);
+    if (playerId === state.playerId && snake) {
+      snakes.set(playerId, { ...snake, snakeBody: [] });
+    } else {
+      snakes.delete(playerId);
+    }
+  });
+
+  const foods = [...(state.foods ?? [])];
+  delta.foodsRemoved?.forEach((food) => {
+    const idx = foods.findIndex((f) => sameFood(f, food));
+    if (idx >= 0) foods.splice(idx, 1);
+  });
+
+  return {
+    ...state,
+    playerSnake: snakes.get(state.playerId) ?? state.playerSnake,
+    otherSnakes: [...snakes.values()].filter((s) => s.playerId !== state.playerId),
+    foods: foods.concat(delta.foodsAdded ?? []),
+  };
+};
+
 interface ChatMessage {
-  type: string;
   from: string;
   message: string;
 }
 
+// Every message in both directions is wrapped in an envelope
+interface Envelope<T = unknown> {
+  type: string;
+  version: number;
+  seq?: number;
+  payload?: T;
+}
+
+interface Standing {
+  playerId: string;
+  // left out for players that never joined
+  placement?: number;
+  score: number;
+  deathReason?: string;
+}
+
+interface GameOver {
+  reason: string;
+  standings: Standing[];
+}
+
 const SnakeGame: React.FC = () => {
   const [gameState, setGameState] = useState<GameState | null>(null);
   const [chatMessage, setChatMessage] = useState("");
   const [chatLog, setChatLog] = useState<ChatMessage[]>([]);
   const [connectionStatus, setConnectionStatus] = useState<"connecting" | "connected" | "disconnected">("connecting");
   const [error, setError] = useState<string | null>(null);
+  const [gameOver, setGameOver] = useState<GameOver | null>(null);
+  const [spectators, setSpectators] = useState(0);
   
   const wsRef = useRef<WebSocket | null>(null);
+  const gameOverRef = useRef(false);
   const canvasRef = useRef<HTMLCanvasElement | null>(null);
   const reconnectTimeoutRef = useRef<ReturnType<typeof setTimeout> | null>(null);
   const lastDirectionRef = useRef<string>("");
+  // numbers every message sent, move seqs come back as acks
+  const seqRef = useRef(0);
+  // -1 while waiting for a snapshot
+  const lastTickRef = useRef(-1);
   const navigate = useNavigate();
 
 
@@ -143,9 +258,9 @@ const SnakeGame: React.FC = () => {
     if (gameState.otherSnakes && gameState.otherSnakes.length > 0) {
       gameState.otherSnakes.forEach((snake, index) => {
         if (snake && snake.snakeBody && snake.snakeHead) {
-          // Different colors for different snakes
+          // The server assigns every snake a color, fall back for older servers
           const colors = ["#ffaa00", "#00aaff", "#ff00aa", "#aaff00"];
-          const color = colors[index % colors.length];
+          const color = snake.color || colors[index % colors.length];
           
           // Draw body
           ctx.fillStyle = color;
@@ -225,6 +340,13 @@ const SnakeGame: React.FC = () => {
     }
   }, [gameState]);
 
+  // send wraps a message in the protocol envelope
+  const send = useCallback((ws: WebSocket, type: string, payload?: unknown) => {
+    seqRef.current += 1;
+    const envelope: Envelope = { type, version: PROTOCOL_VERSION, seq: seqRef.current, payload };
+    ws.send(JSON.stringify(envelope));
+  }, []);
+
   // End Game handler — closes WebSocket cleanly
 const endGameHandler = () => {
   if (wsRef.current && wsRef.current.readyState === WebSocket.OPEN) {
@@ -249,28 +371,75 @@ const endGameHandler = () => {
       wsRef.current = ws;
       setConnectionStatus("connecting");
 
+      let resumeToken: string | undefined;
+
       ws.onopen = () => {
         console.log("Connected to WebSocket");
-        setConnectionStatus("connected");
-        setError(null);
+        // The server tracks move sequence numbers per connection and sends
+        // a snapshot to every new one once the handshake is done
+        seqRef.current = 0;
+        lastTickRef.current = -1;
+        resumeToken = sessionStorage.getItem(resumeTokenKey(gameId)) ?? undefined;
+        send(ws, "hello", { resumeToken });
       };
 
       ws.onmessage = (event) => {
         try {
-          const data = JSON.parse(event.data);
-          console.log("Received:", data);
-
-          if (data.type === "chat") {
+          const envelope: Envelope = JSON.parse(event.data);
+          // eslint-disable-next-line @typescript-eslint/no-explicit-any
+          const data: any = envelope.payload ?? {};
+          console.log("Received:", envelope);
+
+          if (envelope.type === "welcome") {
+            sessionStorage.setItem(resumeTokenKey(gameId), data.resumeToken);
+            setConnectionStatus("connected");
+            setError(null);
+          } else if (envelope.type === "error") {
+            console.warn(`Server rejected message ${envelope.seq ?? "-"}: ${data.code} ${data.message}`);
+            if (data.code === "unsupported_version" || data.code === "handshake_required") {
+              setError(`Protocol error: ${data.message}`);
+            } else if (data.code === "invalid_resume_token") {
+              if (resumeToken) {
+                // The token is stale, the session was taken over since. Retry
+                // without it, which works once no other window is connected.
+                sessionStorage.removeItem(resumeTokenKey(gameId));
+              } else {
+                // Another window is connected as this player, retrying won't help
+                gameOverRef.current = true;
+                setError("This match is already being played in another window");
+              }
+            }
+          } else if (envelope.type === "chat") {
             setChatLog((prev) => [
               ...prev,
-              { type: "chat", from: data.from, message: data.message },
+              { from: data.from, message: data.message },
             ]);
-          } else if (data.type === "update" && data.state) {
+          } else if (envelope.type === "snapshot" && data.state) {
+            lastTickRef.current = data.tick;
             setGameState(data.state);
-          } else if (data.playerId) {
-            // Direct game state
-            setGameState(data);
+            setSpectators(data.spectators ?? 0);
+          } else if (envelope.type === "delta") {
+            if (lastTickRef.current < 0) {
+              return;
+            }
+            // A missed delta can't be patched over, ask for a fresh snapshot
+            if (data.tick !== lastTickRef.current + 1) {
+              lastTickRef.current = -1;
+              send(ws, "resync");
+              return;
+            }
+            lastTickRef.current = data.tick;
+            setGameState((prev) => (prev ? applyDelta(prev, data) : prev));
+            // only sent when it changed
+            if (data.spectators !== undefined) {
+              setSpectators(data.spectators);
+            }
+          } else if (envelope.type === "game_over") {
+            // The server closes the match, don't reconnect to it
+            gameOverRef.current = true;
+            setGameOver({ reason: data.reason, standings: data.standings ?? [] });
           }
+          // Other notifications (player_died, achievement) don't change the board
         } catch (err) {
           console.error("Invalid JSON:", event.data, err);
         }
@@ -281,9 +450,20 @@ const endGameHandler = () => {
         setError("WebSocket connection error");
       };
 
-      ws.onclose = () => {
+      ws.onclose = (event) => {
         console.log("Disconnected from WebSocket");
         setConnectionStatus("disconnected");
+        if (event.code === CLOSE_SESSION_REPLACED) {
+          setError("This match was opened in another window");
+          return;
+        }
+        if (event.code === CLOSE_FORBIDDEN || event.code === CLOSE_MATCH_NOT_FOUND) {
+          setError(event.reason || "You can't join this match");
+          return;
+        }
+        if (gameOverRef.current) {
+          return;
+        }
         
         // Attempt to reconnect after 3 seconds
         if (reconnectTimeoutRef.current) {
@@ -299,7 +479,7 @@ const endGameHandler = () => {
       setError("Failed to connect to game server");
       setConnectionStatus("disconnected");
     }
-  }, [gameId, userId]);
+  }, [gameId, userId, send]);
 
   // Initialize WebSocket connection
   useEffect(() => {
@@ -352,15 +532,14 @@ const endGameHandler = () => {
         }
         
         lastDirectionRef.current = dir;
-        const moveCommand = { type: "move", direction: dir };
-        console.log("Sending move:", moveCommand);
-        wsRef.current.send(JSON.stringify(moveCommand));
+        console.log("Sending move:", dir);
+        send(wsRef.current, "move", { direction: dir });
       }
     };
 
     window.addEventListener("keydown", handleKey);
     return () => window.removeEventListener("keydown", handleKey);
-  }, []);
+  }, [send]);
 
   // Send chat message
   const sendChat = useCallback(() => {
@@ -368,15 +547,9 @@ const endGameHandler = () => {
       return;
     }
 
-    const chatData = {
-      type: "chat",
-      from: userId || "Unknown",
-      message: chatMessage.trim(),
-    };
-
-    wsRef.current.send(JSON.stringify(chatData));
+    send(wsRef.current, "chat", { message: chatMessage.trim() });
     setChatMessage("");
-  }, [chatMessage, userId]);
+  }, [chatMessage, send]);
 
   return (
     <div className="flex flex-col items-center justify-center min-h-screen bg-gray-900 text-white p-4">
@@ -398,6 +571,9 @@ const endGameHandler = () => {
             </span>
             <span className="text-gray-400">Room: {gameId}</span>
             <span className="text-gray-400">Player: {userId}</span>
+            {spectators > 0 && (
+              <span className="text-gray-400">👁 {spectators} watching</span>
+            )}
           </div>
           {error && (
             <div className="mt-2 text-red-400 text-sm">{error}</div>
@@ -486,8 +662,25 @@ const endGameHandler = () => {
             </button>
           </div>
         </div>
+        {/* Final standings */}
+        {gameOver && (
+          <div className="bg-gray-800 rounded-lg p-4 mt-4">
+            <h2 className="text-lg font-semibold mb-2">🏁 Game Over</h2>
+            {gameOver.standings.map((s) => (
+              <div
+                key={s.playerId}
+                className={s.playerId === userId ? "text-green-400" : "text-white"}
+              >
+                {s.placement !== undefined ? `#${s.placement}` : "–"} {s.playerId}: {s.score}
+                {s.deathReason && (
+                  <span className="text-gray-400"> ({s.deathReason})</span>
+                )}
+              </div>
+            ))}
+          </div>
+        )}
         {/* End Game button */}
-        {!gameState?.playerSnake?.isAlive ? (
+        {gameOver || !gameState?.playerSnake?.isAlive ? (
           <button
             onClick={endGameHandler}
             className="px-4 py-2 bg-red-500 text-white rounded hover:bg-red-600"